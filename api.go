@@ -0,0 +1,177 @@
+// Package goproject creates Go projects from design files. Run is the
+// command line, Parse and Design.Execute do the same work for programs
+// that embed it.
+package goproject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Options control how Parse reads a design
+type Options struct {
+	// Name is the project name, it takes the place of the project: line
+	Name string
+	// Dir is the directory the design is executed in, the working
+	// directory when empty
+	Dir string
+	// Config holds settings by their config key, eg. author or
+	// module.prefix. They are design variables and supply defaults just
+	// as the config file does for the command line.
+	Config map[string]string
+	// Format is the format the design is written in, FormatNative when
+	// empty, FormatYAML, FormatTOML or FormatJSON
+	Format string
+	// FS is what the exists, isfile, isdir and ingit tests of if: look
+	// at, the disk when nil
+	FS FS
+	// Ask answers the variables the conditions of if: use that are not in
+	// Config, a condition using one is an error when nil
+	Ask func(name string) (string, error)
+	// Log receives the operations of each step at VerbosityInfo and the
+	// workings of the parser at VerbosityDebug, nothing is logged when nil
+	Log func(v Verbosity, msg string)
+}
+
+// ExecOptions control how Design.Execute carries out a design
+type ExecOptions struct {
+	// StopOnError skips the remaining steps after the first that fails
+	StopOnError bool
+	// FS receives every file the design writes, the disk when nil. With a
+	// MemFS or an OverlayFS over OSFS nothing is written to disk.
+	FS FS
+	// Runner runs the commands of exec:, get:, module: and git-init:, for
+	// real when nil. A RecordingRunner or ReplayRunner lets designs be
+	// tested without go, git or wget.
+	Runner Runner
+	// Events receives the progress of execution as it happens. Output of
+	// the commands is only delivered as NodeOutput events when it is set.
+	Events func(Event)
+}
+
+// Result counts the steps Design.Execute carried out
+type Result struct {
+	Steps       int          // steps in the design
+	Done        int          // steps that succeeded
+	Failed      int          // steps that failed
+	Interrupted int          // steps cancelled while they ran
+	Skipped     int          // steps never run because of cancellation or StopOnError
+	Status      []NodeStatus // what became of each step, in the order of Design.Nodes
+	Errors      []error      // what went wrong, in the order it happened
+}
+
+// ParseError is returned by Parse for a design with errors
+type ParseError struct {
+	Errs []error
+}
+
+func (e *ParseError) Error() string {
+	var s []string
+	for _, err := range e.Errs {
+		s = append(s, err.Error())
+	}
+	return "invalid design: " + strings.Join(s, "; ")
+}
+
+// Design is a parsed design ready to be executed
+type Design struct {
+	p *designParser
+}
+
+//─────────────┤ Parse ├─────────────
+
+// Parse reads a design from r. Unlike the command line it does not pass
+// the text through xpanda, ${project} and the settings of opts.Config are
+// the only variables. A design with errors returns a *ParseError.
+func Parse(r io.Reader, opts Options) (*Design, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	format, err := designFormat("", opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := designLines(string(b), format)
+	if err != nil {
+		return nil, err
+	}
+
+	root := opts.Dir
+	if root == "" {
+		root = "."
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config{}
+	for k, v := range opts.Config {
+		cfg[k] = v
+	}
+
+	po := parseOptions{ask: opts.Ask, fs: opts.FS}
+	if opts.Log != nil {
+		po.logf = func(v Verbosity, format string, args ...any) { opts.Log(v, fmt.Sprintf(format, args...)) }
+	}
+	p := parseDesignIn(opts.Name, dsn, root, cfg, po)
+	if p.hasErrors() {
+		return nil, &ParseError{Errs: p.errs}
+	}
+	return &Design{p: p}, nil
+}
+
+// Project is the name of the project the design creates
+func (d *Design) Project() string {
+	return d.p.project
+}
+
+// Nodes are the steps of the design in the order Execute carries them out
+func (d *Design) Nodes() []Node {
+	return makePlan("", d.p).Nodes
+}
+
+//─────────────┤ Execute ├─────────────
+
+// Execute creates the project. Once ctx is done running commands are killed
+// and no further steps are started, the error is then ctx.Err(). Otherwise
+// it is non-nil when any step failed and the Result tells how far the
+// design got.
+func (d *Design) Execute(ctx context.Context, opts ExecOptions) (*Result, error) {
+	d.p.fs, d.p.runner, d.p.events = opts.FS, opts.Runner, opts.Events
+	if d.p.fs == nil {
+		d.p.fs = OSFS{}
+	}
+	if d.p.runner == nil {
+		d.p.runner = ExecRunner{}
+	}
+	errs := len(d.p.errs)
+	sum := executeAst(ctx, d.p, opts.StopOnError)
+
+	res := &Result{
+		Steps:       len(sum.status),
+		Done:        sum.count(NodeDone),
+		Failed:      sum.count(NodeFailed),
+		Interrupted: sum.count(NodeInterrupted),
+		Skipped:     sum.count(NodeNotRun),
+		Status:      sum.status,
+		Errors:      append([]error{}, d.p.errs[errs:]...),
+	}
+
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	if res.Failed > 0 {
+		err := errors.New("no error reported")
+		if len(res.Errors) > 0 {
+			err = res.Errors[0]
+		}
+		return res, fmt.Errorf("%d of %d steps failed, the first with %w", res.Failed, res.Steps, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,312 @@
+package goproject
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"bitbucket.org/creachadair/shell"
+)
+
+const (
+	DefaultCaptureMaxSize    = 64 * 1024
+	DefaultCaptureInlineSize = 4 * 1024
+)
+
+// files go-project regenerates itself rather than copies
+var captureSkip = map[string]bool{
+	"go.mod":      true,
+	"go.sum":      true,
+	"go.work":     true,
+	"go.work.sum": true,
+}
+
+type captureOptions struct {
+	name       string
+	maxSize    int64
+	inlineSize int64  // largest text file written into the design
+	source     string // directory copy: reads from as ${source}, absolute paths when empty
+}
+
+// capture is the state of a single capture run. warnings collects the
+// things that could not be expressed in the design.
+type capture struct {
+	root     string
+	opts     captureOptions
+	ignore   gitIgnore
+	warnings []string
+}
+
+//─────────────┤ captureDesign ├─────────────
+
+// captureDesign walks root and returns a design that recreates its
+// directories. Text files no larger than inlineSize are written into the
+// design, other files no larger than maxSize become copy: entries that
+// read from root. With a source directory the copy: paths are relative to
+// ${source}, which init is given with --var, otherwise they are absolute
+// and the design is tied to the captured tree.
+func captureDesign(root string, opts captureOptions) (string, []string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", nil, err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return "", nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	if opts.name == "" {
+		opts.name = filepath.Base(root)
+	}
+	if opts.maxSize <= 0 {
+		opts.maxSize = DefaultCaptureMaxSize
+	}
+	from := root
+	if opts.source != "" {
+		if opts.source, err = filepath.Abs(opts.source); err != nil {
+			return "", nil, err
+		}
+		if from, err = filepath.Rel(opts.source, root); err != nil {
+			return "", nil, err
+		}
+	}
+
+	c := capture{root: root, opts: opts}
+
+	var b strings.Builder
+	b.WriteString("begin-design:\n")
+	fmt.Fprintf(&b, "# captured from %s by go-project capture\n", from)
+	if opts.source != "" {
+		b.WriteString("# copy: reads from ${source}, give it to init with --var source=<dir>\n")
+	}
+	fmt.Fprintf(&b, "project: %s\n\n", opts.name)
+	err = c.dir(&b, ".", 0)
+	b.WriteString("end-design:\n")
+
+	return b.String(), c.warnings, err
+}
+
+//─────────────┤ dir ├─────────────
+
+func (c *capture) dir(b *strings.Builder, rel string, depth int) error {
+	abs := filepath.Join(c.root, rel)
+	c.ignore.load(abs, rel)
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return err
+	}
+
+	ind := strings.Repeat("    ", depth+1)
+	var body strings.Builder
+	var work, git string
+
+	if mod := goModPath(filepath.Join(abs, "go.mod")); mod != "" {
+		fmt.Fprintf(&body, "%smodule: %s\n", ind, mod)
+	}
+
+	var subdirs []string
+	for _, e := range entries {
+		name := e.Name()
+		erel := filepath.Join(rel, name)
+
+		if name == ".git" {
+			git = gitInitText(filepath.Join(abs, name), ind)
+			continue
+		}
+		if name == "go.work" {
+			work = goWorkText(filepath.Join(abs, name), ind)
+			continue
+		}
+		if captureSkip[name] || c.ignore.ignored(erel, e.IsDir()) {
+			continue
+		}
+		if strings.ContainsAny(name, "()") {
+			c.warnings = append(c.warnings, fmt.Sprintf("skipped %s, parentheses cannot be used in a design", erel))
+			continue
+		}
+
+		if e.IsDir() {
+			subdirs = append(subdirs, erel)
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if info.Size() > c.opts.maxSize {
+			fmt.Fprintf(&body, "%s# skipped %s, %d bytes\n", ind, name, info.Size())
+			c.warnings = append(c.warnings, fmt.Sprintf("skipped %s, %d bytes is over the size limit", erel, info.Size()))
+			continue
+		}
+
+		src := filepath.Join(abs, name)
+		if info.Size() <= c.opts.inlineSize {
+			if text, ok := inlineFile(src, name, ind); ok {
+				body.WriteString(text)
+				continue
+			}
+		}
+		if c.opts.source != "" {
+			if rel, err := filepath.Rel(c.opts.source, src); err == nil {
+				src = "${source}/" + filepath.ToSlash(rel)
+			}
+		}
+		if strings.ContainsAny(src, " \t") {
+			src = `"` + src + `"`
+		}
+		fmt.Fprintf(&body, "%scopy: %s\n", ind, src)
+	}
+
+	for _, sd := range subdirs {
+		if err := c.dir(&body, sd, depth+1); err != nil {
+			return err
+		}
+	}
+
+	// workspace: needs the go.mod of every module so it follows the subdirectories
+	body.WriteString(work)
+	body.WriteString(git)
+
+	dir := "${project}"
+	if rel != "." {
+		dir += "/" + filepath.ToSlash(rel)
+	}
+
+	pre := strings.Repeat("    ", depth)
+	if body.Len() == 0 {
+		fmt.Fprintf(b, "%sdir: %s\n", pre, dir)
+		return nil
+	}
+	fmt.Fprintf(b, "%sdir: %s (\n%s%s)\n", pre, dir, body.String(), pre)
+	return nil
+}
+
+//─────────────┤ inlineFile ├─────────────
+
+// inlineFile returns an exec: of printf that writes the text of file to
+// name. A file that is not text, or whose lines the design would read as
+// something else, is not inlined.
+func inlineFile(file, name, ind string) (string, bool) {
+	b, err := os.ReadFile(file)
+	if err != nil || !utf8.Valid(b) || strings.ContainsAny(string(b), "\x00\r") || strings.Contains(string(b), "${") {
+		return "", false
+	}
+	// the text lines are not indented, their indentation is part of the file
+	text := fmt.Sprintf("%sexec: (\n%s    printf %%s %s > %s\n%s)\n", ind, ind, shell.Quote(string(b)), name, ind)
+
+	// the step the design reads must write the file as it is
+	dsn := strings.Split("begin-design:\n\n"+text+"end-design:", "\n")
+	p := parseDesignIn("", dsn, "/", config{}, parseOptions{ask: blankAnswer})
+	if p.hasErrors() || len(p.ast.q) != 1 || p.ast.q[0].cmd != CmdExec {
+		return "", false
+	}
+	fsys, r := NewMemFS(), &RecordingRunner{}
+	if err := execCmd(context.Background(), r, fsys, io.Discard, p.ast.q[0]); err != nil {
+		return "", false
+	}
+	if _, err := fsys.Stat(filepath.Join("/", name)); err != nil || len(r.Calls) != 1 ||
+		!reflect.DeepEqual(r.Calls[0].Args, []string{"printf", "%s", string(b)}) {
+		return "", false
+	}
+	return text, true
+}
+
+//─────────────┤ goModPath ├─────────────
+
+func goModPath(file string) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+//─────────────┤ goWorkText ├─────────────
+
+// goWorkText turns a go.work file into a workspace: directive
+func goWorkText(file, ind string) string {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+
+	var lines []string
+	var block string
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimSpace(l)
+		if i := strings.Index(l, "//"); i >= 0 {
+			l = strings.TrimSpace(l[:i])
+		}
+		switch {
+		case l == "":
+		case l == ")":
+			block = ""
+		case strings.HasSuffix(l, "("):
+			block = strings.TrimSpace(strings.TrimSuffix(l, "("))
+		case block == "use":
+			lines = append(lines, l)
+		case block == "replace":
+			lines = append(lines, "replace "+l)
+		case block != "":
+		case strings.HasPrefix(l, "go "):
+			lines = append(lines, l)
+		case strings.HasPrefix(l, "use "):
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(l, "use ")))
+		case strings.HasPrefix(l, "replace "):
+			lines = append(lines, l)
+		}
+	}
+
+	ret := ind + "workspace: (\n"
+	for _, l := range lines {
+		ret += ind + "    " + l + "\n"
+	}
+	return ret + ind + ")\n"
+}
+
+//─────────────┤ gitInitText ├─────────────
+
+// gitInitText turns a .git directory into a git-init: directive keeping
+// the current branch and the origin remote.
+func gitInitText(gitdir, ind string) string {
+	var opts []string
+
+	head, err := os.ReadFile(filepath.Join(gitdir, "HEAD"))
+	if err == nil && strings.HasPrefix(string(head), "ref: refs/heads/") {
+		opts = append(opts, "branch "+strings.TrimSpace(strings.TrimPrefix(string(head), "ref: refs/heads/")))
+	}
+
+	cfg, err := os.ReadFile(filepath.Join(gitdir, "config"))
+	if err == nil {
+		re := regexp.MustCompile(`(?m)^\[remote "origin"\]\s*\n(?:[ \t]+.*\n)*?[ \t]+url\s*=\s*(\S+)`)
+		if m := re.FindStringSubmatch(string(cfg)); m != nil && !strings.ContainsAny(m[1], "()") {
+			opts = append(opts, "remote origin "+m[1])
+		}
+	}
+
+	if len(opts) == 0 {
+		return ind + "git-init:\n"
+	}
+	ret := ind + "git-init: (\n"
+	for _, o := range opts {
+		ret += ind + "    " + o + "\n"
+	}
+	return ret + ind + ")\n"
+}
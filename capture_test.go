@@ -0,0 +1,43 @@
+package goproject
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInlineFile(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"go source", "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(\"it's 100%\")\n}\n", true},
+		{"no final newline", "a > b\n  c", true},
+		{"empty", "", true},
+		{"variable", "echo ${HOME}\n", false},
+		{"binary", "\x00\x01", false},
+		{"crlf", "a\r\nb\r\n", false},
+		{"close first", ")\n(\n", false},
+		{"condition", "a\nif: x (\n)\n", false},
+		{"end of design", "a\nend-design:\n", false},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, "f.txt")
+			if err := os.WriteFile(file, []byte(tt.text), 0666); err != nil {
+				t.Fatal(err)
+			}
+			text, ok := inlineFile(file, "f.txt", "    ")
+			if ok != tt.want {
+				t.Fatalf("inlineFile(%q) = %t, want %t\n%s", tt.text, ok, tt.want, text)
+			}
+			if ok && !strings.HasPrefix(text, "    exec: (\n") {
+				t.Errorf("inlineFile(%q) = %q, want an exec: block", tt.text, text)
+			}
+		})
+	}
+}
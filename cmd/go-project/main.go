@@ -25,8 +25,11 @@
 package main
 
 import (
+	"context"
 	"github.com/westarver/go-project"
 	"os"
+	"os/signal"
+	"syscall"
 
 	msg "github.com/westarver/messenger"
 )
@@ -35,5 +38,14 @@ func main() {
 	writer := msg.New()
 	writer.SetOut(os.Stderr)
 
-	os.Exit(goproject.Run(writer))
+	// the first Ctrl-C stops init cleanly, a second one kills go-project
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	code := goproject.RunContext(ctx, writer)
+	stop()
+	os.Exit(code)
 }
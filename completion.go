@@ -0,0 +1,284 @@
+package goproject
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the kind of value completed after a command or flag, anything else that
+// takes a value gets no completion
+var completeKinds = map[string]string{
+	"--design":        "design",
+	"--preset":        "preset",
+	"presets":         "preset",
+	"help":            "topic",
+	"designs":         "action",
+	"config":          "setting",
+	"--format":        "format",
+	"--license":       "license",
+	"--layout":        "layout",
+	"completion":      "shell",
+	"capture":         "dir",
+	"diff":            "dir",
+	"fmt":             "designfile",
+	"new-design":      "file",
+	"--log-file":      "file",
+	"--source":        "dir",
+	"--log-format":    "logformat",
+	"convert":         "designfile",
+	"--to":            "designformat",
+	"--design-format": "designformat",
+}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+//─────────────┤ completionScript ├─────────────
+
+// completionScript returns the completion script for shell. Commands and
+// flags come from the help tables boa is built from, library designs are
+// listed by go-project itself when completing.
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion(), nil
+	case "zsh":
+		return zshCompletion(), nil
+	case "fish":
+		return fishCompletion(), nil
+	}
+	return "", fmt.Errorf("unknown shell %s, use one of %s", shell, strings.Join(completionShells, ", "))
+}
+
+//─────────────┤ completion data ├─────────────
+
+// completeWords returns the command and flag names, aliases included
+func completeWords() (commands, flags []string) {
+	for _, c := range commandHelp {
+		commands = append(commands, c.names()...)
+	}
+	for _, f := range flagHelp {
+		flags = append(flags, f.names()...)
+	}
+	return commands, flags
+}
+
+// takesValue returns every command and flag name that is followed by a value
+func takesValue() []string {
+	var names []string
+	for _, it := range append(append([]helpItem{}, commandHelp...), flagHelp...) {
+		if strings.Contains(it.usage, "<") {
+			names = append(names, it.names()...)
+		}
+	}
+	return names
+}
+
+// namesOfKind returns the commands and flags, with their aliases, that
+// complete kind
+func namesOfKind(kind string) []string {
+	var names []string
+	for _, it := range append(append([]helpItem{}, commandHelp...), flagHelp...) {
+		if completeKinds[it.names()[0]] == kind {
+			names = append(names, it.names()...)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func helpTopics() []string {
+	commands, flags := completeWords()
+	return append(append(commands, flags...), "design", "directives", "directive", "exit-codes", "markdown")
+}
+
+func configKeyNames() []string {
+	var names []string
+	for _, k := range configKeys {
+		names = append(names, k.key)
+	}
+	return names
+}
+
+func directiveNames() []string {
+	var names []string
+	for _, d := range directivesHelp {
+		names = append(names, d.name)
+	}
+	return append(names, registeredKeywords()...)
+}
+
+// staticValues are the words completed for each kind known in advance
+func staticValues() map[string][]string {
+	return map[string][]string{
+		"preset":       presetNames(),
+		"topic":        helpTopics(),
+		"action":       {"list", "show", "add", "remove"},
+		"setting":      {"list", "get", "set", "path"},
+		"format":       {"text", "tree", "json"},
+		"license":      append(licenseNames(), NoLicense),
+		"layout":       layoutDirs,
+		"shell":        completionShells,
+		"logformat":    {"text", "json"},
+		"designformat": designFormats,
+	}
+}
+
+//─────────────┤ bashCompletion ├─────────────
+
+func bashCompletion() string {
+	commands, flags := completeWords()
+	vals := staticValues()
+	alt := func(kind string) string { return strings.Join(namesOfKind(kind), "|") }
+	var globs []string
+	for _, ext := range libraryExts {
+		globs = append(globs, "$(compgen -f -X '!*"+ext+"' -- \"$cur\")")
+	}
+	files := strings.Join(globs, " ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s, generated by %s completion bash\n", AppName, AppName)
+	b.WriteString("_go_project() {\n")
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\" prev2=\"\"\n")
+	b.WriteString("    [ \"$COMP_CWORD\" -ge 2 ] && prev2=\"${COMP_WORDS[COMP_CWORD-2]}\"\n")
+	b.WriteString("\n")
+
+	b.WriteString("    case \"$prev2 $prev\" in\n")
+	fmt.Fprintf(&b, "        \"help directive\") COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(directiveNames(), " "))
+	fmt.Fprintf(&b, "        \"designs show\"|\"designs remove\") COMPREPLY=($(compgen -W \"$(%s designs list 2>/dev/null)\" -- \"$cur\")); return ;;\n", AppName)
+	fmt.Fprintf(&b, "        \"designs add\") compopt -o filenames 2>/dev/null; COMPREPLY=(%s $(compgen -d -- \"$cur\")); return ;;\n", files)
+	fmt.Fprintf(&b, "        \"config get\"|\"config set\") COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(configKeyNames(), " "))
+	b.WriteString("    esac\n\n")
+
+	b.WriteString("    case \"$prev\" in\n")
+	fmt.Fprintf(&b, "        %s) compopt -o filenames 2>/dev/null; COMPREPLY=(%s $(compgen -d -- \"$cur\") $(compgen -W \"$(%s designs list 2>/dev/null)\" -- \"$cur\")); return ;;\n", alt("design"), files, AppName)
+	fmt.Fprintf(&b, "        %s) compopt -o filenames 2>/dev/null; COMPREPLY=(%s $(compgen -d -- \"$cur\")); return ;;\n", alt("designfile"), files)
+	fmt.Fprintf(&b, "        %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", alt("dir"))
+	fmt.Fprintf(&b, "        %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", alt("file"))
+	for _, kind := range sortedKinds(vals) {
+		fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", alt(kind), strings.Join(vals[kind], " "))
+	}
+	fmt.Fprintf(&b, "        %s) COMPREPLY=(); return ;;\n", strings.Join(takesValue(), "|"))
+	b.WriteString("    esac\n\n")
+
+	fmt.Fprintf(&b, "    COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(append(commands, flags...), " "))
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -F _go_project %s\n", AppName)
+	return b.String()
+}
+
+//─────────────┤ zshCompletion ├─────────────
+
+func zshCompletion() string {
+	commands, flags := completeWords()
+	vals := staticValues()
+	alt := func(kind string) string { return strings.Join(namesOfKind(kind), "|") }
+
+	files := "'*(" + strings.Join(libraryExts, "|") + ")'"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", AppName)
+	fmt.Fprintf(&b, "# zsh completion for %s, generated by %s completion zsh\n", AppName, AppName)
+	b.WriteString("__go_project_library() {\n")
+	b.WriteString("    local -a library\n")
+	fmt.Fprintf(&b, "    library=(${(f)\"$(%s designs list 2>/dev/null)\"})\n", AppName)
+	b.WriteString("    compadd -a library\n")
+	b.WriteString("}\n\n")
+	b.WriteString("_go_project() {\n")
+	b.WriteString("    local prev=${words[CURRENT-1]} prev2=${words[CURRENT-2]}\n")
+	b.WriteString("\n")
+
+	b.WriteString("    case \"$prev2 $prev\" in\n")
+	fmt.Fprintf(&b, "        \"help directive\") compadd -- %s; return ;;\n", strings.Join(directiveNames(), " "))
+	b.WriteString("        \"designs show\"|\"designs remove\") __go_project_library; return ;;\n")
+	fmt.Fprintf(&b, "        \"designs add\") _files -g %s; return ;;\n", files)
+	fmt.Fprintf(&b, "        \"config get\"|\"config set\") compadd -- %s; return ;;\n", strings.Join(configKeyNames(), " "))
+	b.WriteString("    esac\n\n")
+
+	b.WriteString("    case $prev in\n")
+	fmt.Fprintf(&b, "        %s) _files -g %s; __go_project_library; return ;;\n", alt("design"), files)
+	fmt.Fprintf(&b, "        %s) _files -g %s; return ;;\n", alt("designfile"), files)
+	fmt.Fprintf(&b, "        %s) _directories; return ;;\n", alt("dir"))
+	fmt.Fprintf(&b, "        %s) _files; return ;;\n", alt("file"))
+	for _, kind := range sortedKinds(vals) {
+		fmt.Fprintf(&b, "        %s) compadd -- %s; return ;;\n", alt(kind), strings.Join(vals[kind], " "))
+	}
+	fmt.Fprintf(&b, "        %s) return ;;\n", strings.Join(takesValue(), "|"))
+	b.WriteString("    esac\n\n")
+
+	fmt.Fprintf(&b, "    compadd -- %s\n", strings.Join(append(commands, flags...), " "))
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "if [ \"$funcstack[1]\" = \"_go_project\" ]; then\n    _go_project \"$@\"\nelse\n    compdef _go_project %s\nfi\n", AppName)
+	return b.String()
+}
+
+//─────────────┤ fishCompletion ├─────────────
+
+func fishCompletion() string {
+	vals := staticValues()
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", `\'`) + "'" }
+	prev := func(kind string) string { return quote("__go_project_prev " + strings.Join(namesOfKind(kind), " ")) }
+	var suffixes []string
+	for _, ext := range libraryExts {
+		suffixes = append(suffixes, "(__fish_complete_suffix "+ext+")")
+	}
+	files := strings.Join(suffixes, " ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s, generated by %s completion fish\n", AppName, AppName)
+	b.WriteString("function __go_project_prev\n")
+	b.WriteString("    set -l t (commandline -opc)\n")
+	b.WriteString("    test (count $t) -ge 2; and contains -- $t[-1] $argv\n")
+	b.WriteString("end\n\n")
+	b.WriteString("function __go_project_prev2\n")
+	b.WriteString("    set -l t (commandline -opc)\n")
+	b.WriteString("    test (count $t) -ge 3; and test \"$t[-2]\" = $argv[1]; and contains -- $t[-1] $argv[2..-1]\n")
+	b.WriteString("end\n\n")
+	b.WriteString("function __go_project_free\n")
+	fmt.Fprintf(&b, "    not __go_project_prev %s\n", strings.Join(takesValue(), " "))
+	b.WriteString("end\n\n")
+
+	fmt.Fprintf(&b, "complete -c %s -f\n", AppName)
+	for _, c := range commandHelp {
+		for _, n := range c.names() {
+			fmt.Fprintf(&b, "complete -c %s -n __go_project_free -a %s -d %s\n", AppName, n, quote(c.short))
+		}
+	}
+	for _, f := range flagHelp {
+		var opt string
+		for _, n := range f.names() {
+			switch {
+			case strings.HasPrefix(n, "--"):
+				opt += " -l " + strings.TrimPrefix(n, "--")
+			case len(n) > 2: // a single dash alias of more than one letter, -vv
+				opt += " -o " + strings.TrimPrefix(n, "-")
+			default:
+				opt += " -s " + strings.TrimPrefix(n, "-")
+			}
+		}
+		fmt.Fprintf(&b, "complete -c %s -n __go_project_free%s -d %s\n", AppName, opt, quote(f.short))
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "complete -c %s -n '__go_project_prev2 help directive' -a %s\n", AppName, quote(strings.Join(directiveNames(), " ")))
+	fmt.Fprintf(&b, "complete -c %s -n '__go_project_prev2 designs show remove' -a '(%s designs list 2>/dev/null)'\n", AppName, AppName)
+	fmt.Fprintf(&b, "complete -c %s -n '__go_project_prev2 designs add' -a '%s'\n", AppName, files)
+	fmt.Fprintf(&b, "complete -c %s -n '__go_project_prev2 config get set' -a %s\n", AppName, quote(strings.Join(configKeyNames(), " ")))
+	fmt.Fprintf(&b, "complete -c %s -n %s -a '%s (%s designs list 2>/dev/null)'\n", AppName, prev("design"), files, AppName)
+	fmt.Fprintf(&b, "complete -c %s -n %s -a '%s'\n", AppName, prev("designfile"), files)
+	fmt.Fprintf(&b, "complete -c %s -n %s -a '(__fish_complete_directories)'\n", AppName, prev("dir"))
+	fmt.Fprintf(&b, "complete -c %s -n %s -F\n", AppName, prev("file"))
+	for _, kind := range sortedKinds(vals) {
+		fmt.Fprintf(&b, "complete -c %s -n %s -a %s\n", AppName, prev(kind), quote(strings.Join(vals[kind], " ")))
+	}
+	return b.String()
+}
+
+func sortedKinds(vals map[string][]string) []string {
+	var kinds []string
+	for k := range vals {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
@@ -0,0 +1,414 @@
+package goproject
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// a variable expandVars found no value for
+var unsetVar = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// the name of a variable set with --var
+var varName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+//─────────────┤ resolveConditions ├─────────────
+
+// resolveConditions evaluates the if: blocks between lines lo and hi. The
+// lines of the branch not taken become blank, as do the if: and else: lines
+// and their parentheses, so the scanners never see a condition and line
+// numbers stay as they are. A condition is read as written, its variables
+// are expanded operand by operand so a value with spaces stays one.
+func (d *designParser) resolveConditions(lo, hi int) {
+	for i := lo; i < hi; i++ {
+		l := strings.TrimSpace(d.text[i])
+		if d.regexs[ElsePattern].MatchString(l) {
+			d.setError(fmt.Errorf("else: without if: at line %d", i+1))
+			d.text[i] = ""
+			continue
+		}
+		if !d.regexs[IfPattern].MatchString(l) {
+			continue
+		}
+
+		expr := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(d.src[i]), "if:"))
+		if !strings.HasSuffix(expr, "(") {
+			d.setError(fmt.Errorf("if: must open a block at line %d", i+1))
+			d.text[i] = ""
+			continue
+		}
+		expr = strings.TrimSpace(strings.TrimSuffix(expr, "("))
+		end, ok := d.closeBlock(i, hi)
+		if !ok {
+			d.setError(fmt.Errorf("unbalanced parentheses in if: at line %d", i+1))
+			return
+		}
+
+		// else: follows the close, on its line or a later one
+		els, elsEnd := -1, -1
+		j := end
+		if strings.TrimSpace(d.text[j]) == "" {
+			for j++; j < hi && strings.TrimSpace(d.text[j]) == ""; j++ {
+			}
+		}
+		if j < hi && d.regexs[ElsePattern].MatchString(d.text[j]) {
+			if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(d.text[j]), "else:")) != "(" {
+				d.setError(fmt.Errorf("else: must open a block at line %d", j+1))
+				d.text[j] = ""
+				j = hi
+			}
+		}
+		if j < hi && d.regexs[ElsePattern].MatchString(d.text[j]) {
+			els = j
+			if elsEnd, ok = d.closeBlock(j, hi); !ok {
+				d.setError(fmt.Errorf("unbalanced parentheses in else: at line %d", j+1))
+				return
+			}
+		}
+
+		yes, err := d.evalCondition(expr)
+		if err != nil {
+			// neither branch is taken
+			d.setError(fmt.Errorf("if: %v at line %d", err, i+1))
+		}
+		d.debugf("line %d: if: %s is %t", i+1, expr, yes)
+
+		d.text[i] = ""
+		d.takeBranch(i+1, end, err == nil && yes)
+		if els >= 0 {
+			d.text[els] = ""
+			d.takeBranch(els+1, elsEnd, err == nil && !yes)
+			end = elsEnd
+		}
+		i = end
+	}
+}
+
+// takeBranch resolves the conditions of the lines from lo to hi when take
+// is set and blanks them otherwise. Line hi is the close of the block, it
+// keeps what follows the parenthesis.
+func (d *designParser) takeBranch(lo, hi int, take bool) {
+	if take {
+		d.resolveConditions(lo, hi)
+	} else {
+		for i := lo; i < hi; i++ {
+			d.text[i] = ""
+		}
+	}
+	if strings.TrimSpace(d.text[hi]) == "" {
+		d.text[hi] = ""
+	}
+}
+
+// closeBlock finds the parenthesis closing the block opened at the end of
+// line i and removes it. It returns the line it was on.
+func (d *designParser) closeBlock(i, hi int) (int, bool) {
+	depth := 1
+	for k := i + 1; k < hi; k++ {
+		if d.regexs[CommentPattern].MatchString(d.text[k]) {
+			continue
+		}
+		for c, r := range d.text[k] {
+			switch r {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				d.text[k] = d.text[k][:c] + d.text[k][c+1:]
+				return k, true
+			}
+		}
+	}
+	return 0, false
+}
+
+//─────────────┤ evalCondition ├─────────────
+
+// evalCondition evaluates the expression of an if: line. Operands are
+// words or quoted strings compared with == and !=, combined with !, && and
+// || and grouped with parentheses. exists, isfile, isdir, ingit and onpath
+// test paths and tools, an operand on its own is a yes or no.
+func (d *designParser) evalCondition(expr string) (bool, error) {
+	toks, err := condTokens(expr)
+	if err != nil {
+		return false, err
+	}
+	c := &condParser{d: d, toks: toks}
+	v, err := c.or()
+	if err != nil {
+		return false, err
+	}
+	if c.pos < len(c.toks) {
+		return false, fmt.Errorf("unexpected %s in condition", c.toks[c.pos].text)
+	}
+	return v, nil
+}
+
+type condToken struct {
+	text   string
+	quoted bool // a string, never an operator or function
+}
+
+// condTokens splits a condition into operators, words and strings
+func condTokens(s string) ([]condToken, error) {
+	var toks []condToken
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == ' ' || s[i] == '\t':
+			i++
+		case strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"),
+			strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="):
+			toks = append(toks, condToken{text: s[i : i+2]})
+			i += 2
+		case strings.ContainsRune("()!", rune(s[i])):
+			toks = append(toks, condToken{text: s[i : i+1]})
+			i++
+		case s[i] == '"' || s[i] == '\'':
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in condition")
+			}
+			toks = append(toks, condToken{text: s[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			end := strings.IndexAny(s[i:], " \t()!&|=\"'")
+			if end < 0 {
+				end = len(s) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("unexpected %c in condition", s[i])
+			}
+			toks = append(toks, condToken{text: s[i : i+end]})
+			i += end
+		}
+	}
+	return toks, nil
+}
+
+type condParser struct {
+	d    *designParser
+	toks []condToken
+	pos  int
+}
+
+// is reports whether the next token is the operator op and takes it if so
+func (c *condParser) is(op string) bool {
+	if c.pos < len(c.toks) && !c.toks[c.pos].quoted && c.toks[c.pos].text == op {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+func (c *condParser) or() (bool, error) {
+	v, err := c.and()
+	for err == nil && c.is("||") {
+		var w bool
+		w, err = c.and()
+		v = v || w
+	}
+	return v, err
+}
+
+func (c *condParser) and() (bool, error) {
+	v, err := c.not()
+	for err == nil && c.is("&&") {
+		var w bool
+		w, err = c.not()
+		v = v && w
+	}
+	return v, err
+}
+
+func (c *condParser) not() (bool, error) {
+	if c.is("!") {
+		v, err := c.not()
+		return !v, err
+	}
+	return c.primary()
+}
+
+func (c *condParser) primary() (bool, error) {
+	if c.is("(") {
+		v, err := c.or()
+		if err == nil && !c.is(")") {
+			err = fmt.Errorf("missing ) in condition")
+		}
+		return v, err
+	}
+
+	if c.pos+1 < len(c.toks) && !c.toks[c.pos].quoted && c.toks[c.pos+1].text == "(" && !c.toks[c.pos+1].quoted {
+		return c.call()
+	}
+
+	a, err := c.operand()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case c.is("=="):
+		b, err := c.operand()
+		return a == b, err
+	case c.is("!="):
+		b, err := c.operand()
+		return a != b, err
+	}
+	return truth(a)
+}
+
+// operand is the value of the next word or string
+func (c *condParser) operand() (string, error) {
+	if c.pos >= len(c.toks) {
+		return "", fmt.Errorf("condition ends early")
+	}
+	t := c.toks[c.pos]
+	if !t.quoted && strings.ContainsAny(t.text, "()!&|=") {
+		return "", fmt.Errorf("unexpected %s in condition", t.text)
+	}
+	c.pos++
+	return c.d.expandUnset(expandVars([]string{t.text}, c.d.vars)[0])
+}
+
+// call evaluates a test of a path or a tool, eg. exists(go.mod)
+func (c *condParser) call() (bool, error) {
+	fn := c.toks[c.pos].text
+	c.pos += 2
+	arg, err := c.operand()
+	if err != nil {
+		return false, err
+	}
+	if !c.is(")") {
+		return false, fmt.Errorf("%s takes one argument", fn)
+	}
+
+	if fn == "onpath" {
+		_, err := exec.LookPath(arg)
+		return err == nil, nil
+	}
+	p, err := c.d.condPath(arg)
+	if err != nil {
+		return false, err
+	}
+	info, err := c.d.fs.Stat(p)
+	switch fn {
+	case "exists":
+		return err == nil, nil
+	case "isfile":
+		return err == nil && !info.IsDir(), nil
+	case "isdir":
+		return err == nil && info.IsDir(), nil
+	case "ingit":
+		for dir := p; ; dir = filepath.Dir(dir) {
+			if _, err := c.d.fs.Stat(filepath.Join(dir, ".git")); err == nil {
+				return true, nil
+			}
+			if filepath.Dir(dir) == dir {
+				return false, nil
+			}
+		}
+	}
+	return false, fmt.Errorf("unknown test %s, use exists, isfile, isdir, ingit or onpath", fn)
+}
+
+// condPath resolves a path of a condition against the directory the design
+// is executed in
+func (d *designParser) condPath(p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		return expandHome(p)
+	}
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p), nil
+	}
+	return filepath.Join(d.root, p), nil
+}
+
+// truth reads a yes or no, true, yes, y, on and 1 against false, no, n,
+// off, 0 and nothing at all
+func truth(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "y", "on", "1":
+		return true, nil
+	case "false", "no", "n", "off", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not a yes or no, compare it with == or !=", s)
+}
+
+//─────────────┤ expandUnset ├─────────────
+
+// expandUnset replaces the variables in s that no setting gave a value with
+// the answer of d.ask. An answer is a variable for the rest of the design.
+func (d *designParser) expandUnset(s string) (string, error) {
+	for _, m := range unsetVar.FindAllStringSubmatch(s, -1) {
+		v, ok := d.answers[m[1]]
+		if !ok {
+			if d.ask == nil {
+				return "", fmt.Errorf("variable %s is not set, give it with --var %s=<value>", m[1], m[1])
+			}
+			var err error
+			if v, err = d.ask(m[1]); err != nil {
+				return "", fmt.Errorf("no answer for %s: %v", m[1], err)
+			}
+			d.answers[m[1]] = v
+			d.text = expandVars(d.text, map[string]string{m[1]: v})
+		}
+		s = strings.ReplaceAll(s, m[0], v)
+	}
+	return s, nil
+}
+
+//─────────────┤ promptVar ├─────────────
+
+// promptVar returns an ask function that puts the question on out and
+// reads the answer from in
+func promptVar(in io.Reader, out io.Writer) func(string) (string, error) {
+	r := bufio.NewReader(in)
+	return func(name string) (string, error) {
+		fmt.Fprintf(out, "%s? ", name)
+		l, err := r.ReadString('\n')
+		if err != nil && l == "" {
+			return "", err
+		}
+		return strings.TrimSpace(l), nil
+	}
+}
+
+// blankAnswer answers every variable with nothing, for reading a design
+// only to compare it with another
+func blankAnswer(string) (string, error) {
+	return "", nil
+}
+
+// isTerminal reports whether f is a terminal someone can answer from
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// /dev/null is a character device too
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+//─────────────┤ parseVars ├─────────────
+
+// parseVars reads the value of --var, name=value pairs separated by commas
+func parseVars(s string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		k = strings.TrimSpace(k)
+		if !ok || !varName.MatchString(k) {
+			return nil, fmt.Errorf("invalid --var %s, use name=value", kv)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
@@ -0,0 +1,61 @@
+package goproject
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name string
+		cond string
+		want bool
+	}{
+		{"value with a space", `${author} == "Jane Doe"`, true},
+		{"value with a space unquoted", `${author} != Jane`, true},
+		{"quoted variable", `"${author}" == 'Jane Doe'`, true},
+		{"value with quotes", `${holder} == ${holder} && ${holder} != "it's"`, true},
+		{"value with parentheses", `${license} == "MIT (X11)"`, true},
+		{"yes or no", `${git} && !${empty}`, true},
+		{"os", `${os} == ${os}`, true},
+		{"exists on the fs", `exists(go.mod) && isdir(cmd)`, true},
+		{"missing on the fs", `exists(go.sum) || isfile(cmd)`, false},
+		{"ingit", `ingit(cmd)`, true},
+	}
+
+	fsys := NewMemFS()
+	if err := fsys.MkdirAll("/work/cmd", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.MkdirAll("/work/.git", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("/work/go.mod", nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{"author": "Jane Doe", "holder": `it's "ours"`, "license": "MIT (X11)", "git": "yes", "empty": ""}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn := "begin-design:\nif: " + tt.cond + " (\n    exec: echo yes\n) else: (\n    exec: echo no\n)\nend-design:"
+			p := parseDesignIn("app", strings.Split(dsn, "\n"), "/work", cfg, parseOptions{fs: fsys})
+			if p.hasErrors() {
+				t.Fatalf("if: %s: %s", tt.cond, p.Errors())
+			}
+			var got []string
+			for _, n := range p.ast.q {
+				if n.cmd == CmdExec {
+					got = append(got, n.cmdParams.(string))
+				}
+			}
+			want := []string{"echo no"}
+			if tt.want {
+				want = []string{"echo yes"}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("if: %s ran %q, want %q", tt.cond, got, want)
+			}
+		})
+	}
+}
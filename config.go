@@ -0,0 +1,233 @@
+package goproject
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const ConfigFileName = "config.toml"
+
+// configKeys are the settings config set accepts. Each is available to
+// designs as a variable, ${author} or ${module.prefix}.
+var configKeys = []struct{ key, help string }{
+	{"author", "your name"},
+	{"holder", "copyright holder of licenses, defaults to author"},
+	{"license", "license used by license: without an id and by new-design"},
+	{"module.prefix", "prefix of module: without a path, eg. github.com/ourorg/"},
+	{"git.branch", "initial branch of git-init:"},
+	{"git.user.name", "user.name set by git-init:"},
+	{"git.user.email", "user.email set by git-init:"},
+}
+
+// config holds the settings of config.toml by their dotted key, a key in
+// a [section] is named section.key
+type config map[string]string
+
+//─────────────┤ configPath ├─────────────
+
+// configPath is $XDG_CONFIG_HOME/go-project/config.toml on Linux and the
+// platform equivalent elsewhere.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, AppName, ConfigFileName), nil
+}
+
+//─────────────┤ loadConfig ├─────────────
+
+// loadConfig reads the config file, a missing file is an empty config
+func loadConfig() (config, error) {
+	file, err := configPath()
+	if err != nil {
+		return config{}, err
+	}
+
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return config{}, nil
+	}
+	if err != nil {
+		return config{}, err
+	}
+
+	v, err := parseTOML(string(b))
+	if err != nil {
+		return config{}, fmt.Errorf("%s: %v", file, err)
+	}
+	cfg := config{}
+	if err := cfg.flatten("", v.(map[string]any)); err != nil {
+		return config{}, fmt.Errorf("%s: %v", file, err)
+	}
+	return cfg, nil
+}
+
+//─────────────┤ flatten ├─────────────
+
+// flatten adds the keys of table to cfg, the keys of a [section] table
+// named section.key
+func (cfg config) flatten(prefix string, table map[string]any) error {
+	for k, v := range table {
+		key := prefix + k
+		switch v := v.(type) {
+		case string:
+			cfg[key] = v
+		case map[string]any:
+			if err := cfg.flatten(key+".", v); err != nil {
+				return err
+			}
+		default:
+			if prefix == "" && k == "steps" { // the comments of the file
+				continue
+			}
+			return fmt.Errorf("%s is not a string, a number or a boolean", key)
+		}
+	}
+	return nil
+}
+
+//─────────────┤ String ├─────────────
+
+// String renders cfg as TOML, keys without a dot first and the rest in a
+// table named by all but their last element.
+func (cfg config) String() string {
+	split := func(k string) (string, string) {
+		if i := strings.LastIndex(k, "."); i >= 0 {
+			return k[:i], k[i+1:]
+		}
+		return "", k
+	}
+
+	var keys []string
+	for k := range cfg {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ti, ki := split(keys[i])
+		tj, kj := split(keys[j])
+		if ti != tj {
+			return ti < tj
+		}
+		return ki < kj
+	})
+
+	var b strings.Builder
+	table := ""
+	for _, k := range keys {
+		t, key := split(k)
+		if t != table {
+			var path []string
+			for _, p := range strings.Split(t, ".") {
+				path = append(path, tomlKey(p))
+			}
+			fmt.Fprintf(&b, "\n[%s]\n", strings.Join(path, "."))
+			table = t
+		}
+		fmt.Fprintf(&b, "%s = %s\n", tomlKey(key), quoteString(cfg[k]))
+	}
+	return strings.TrimPrefix(b.String(), "\n")
+}
+
+//─────────────┤ holder ├─────────────
+
+// holder is the copyright holder, the author when none is set
+func (cfg config) holder() string {
+	if cfg["holder"] != "" {
+		return cfg["holder"]
+	}
+	return cfg["author"]
+}
+
+//─────────────┤ modulePath ├─────────────
+
+// modulePath is the module.prefix setting joined to path by a single /,
+// or path alone when no prefix is set
+func (cfg config) modulePath(path string) string {
+	prefix := strings.TrimSuffix(cfg["module.prefix"], "/")
+	if prefix == "" {
+		return path
+	}
+	return prefix + "/" + path
+}
+
+//─────────────┤ doConfig ├─────────────
+
+// doConfig carries out config list, get <key> and set <key> <value>.
+// set rewrites the whole file, comments in it are not kept.
+func doConfig(args []string) (string, error) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+
+	switch args[0] {
+	case "list":
+		var b strings.Builder
+		for _, k := range configKeys {
+			fmt.Fprintf(&b, "%-15s = %-30q # %s\n", k.key, cfg[k.key], k.help)
+		}
+		return b.String(), nil
+	case "get":
+		if len(args) != 2 {
+			return "", errors.New("usage: config get <key>")
+		}
+		v, ok := cfg[args[1]]
+		if !ok {
+			return "", fmt.Errorf("%s is not set", args[1])
+		}
+		return v + "\n", nil
+	case "set":
+		if len(args) < 2 {
+			return "", errors.New("usage: config set <key> [value]")
+		}
+		if err := checkConfig(args[1], strings.Join(args[2:], " ")); err != nil {
+			return "", err
+		}
+		if len(args) == 2 {
+			delete(cfg, args[1])
+		} else {
+			cfg[args[1]] = strings.Join(args[2:], " ")
+		}
+
+		file, err := configPath()
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(file), 0777)
+		}
+		if err == nil {
+			err = os.WriteFile(file, []byte(cfg.String()), 0666)
+		}
+		return "", err
+	case "path":
+		file, err := configPath()
+		return file + "\n", err
+	}
+
+	return "", fmt.Errorf("unknown config action %s, use list, get, set or path", args[0])
+}
+
+func checkConfig(key, val string) error {
+	for _, k := range configKeys {
+		if k.key != key {
+			continue
+		}
+		if key == "license" && val != "" {
+			return checkLicense(val)
+		}
+		return nil
+	}
+
+	var keys []string
+	for _, k := range configKeys {
+		keys = append(keys, k.key)
+	}
+	return fmt.Errorf("unknown config key %s, use one of %s", key, strings.Join(keys, ", "))
+}
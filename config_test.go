@@ -0,0 +1,56 @@
+package goproject
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want config
+	}{
+		{"empty", "", config{}},
+		{"keys", "author = \"Jane Doe\"\nlicense = 'MIT'\n", config{"author": "Jane Doe", "license": "MIT"}},
+		{"tables", "[module]\nprefix = \"example.com/\"\n[git]\nbranch = \"main\"\n[git.user]\nname = \"jd\"\n",
+			config{"module.prefix": "example.com/", "git.branch": "main", "git.user.name": "jd"}},
+		{"comments", "# settings\nauthor = \"jd\" # me\n", config{"author": "jd"}},
+		{"bare value", "holder = 12\n", config{"holder": "12"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseTOML(tt.src)
+			if err != nil {
+				t.Fatalf("parseTOML(%q): %v", tt.src, err)
+			}
+			got := config{}
+			if err := got.flatten("", v.(map[string]any)); err != nil {
+				t.Fatalf("flatten(%q): %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("config of %q = %v, want %v", tt.src, got, tt.want)
+			}
+
+			// what String writes reads back as the same config
+			v, err = parseTOML(got.String())
+			if err != nil {
+				t.Fatalf("parseTOML(%q): %v", got.String(), err)
+			}
+			again := config{}
+			if err := again.flatten("", v.(map[string]any)); err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("config %q reads back as %v, %v", got.String(), again, err)
+			}
+		})
+	}
+
+	v, err := parseTOML("author = [\"a\", \"b\"]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (config{}).flatten("", v.(map[string]any)); err == nil || !strings.Contains(err.Error(), "author is not a string") {
+		t.Errorf("flatten of an array error = %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package goproject
 import (
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 
 	path "github.com/rhysd/abspath"
@@ -25,6 +26,12 @@ const (
 	ModulePattern     = `^\s*module:`
 	WorkspacePattern  = `^\s*workspace:`
 	GitInitPattern    = `^\s*git-init:`
+	MainPattern       = `^\s*main:`
+	PackagePattern    = `^\s*package:`
+	TestPattern       = `^\s*test:`
+	LicensePattern    = `^\s*license:`
+	IfPattern         = `^\s*if:`
+	ElsePattern       = `^\s*else:`
 )
 
 type CommandToken int
@@ -38,8 +45,36 @@ const (
 	CmdModule
 	CmdWorkspace
 	CmdGitInit
+	CmdMain
+	CmdPackage
+	CmdTest
+	CmdLicense
+	CmdCustom // a directive added with Register
 )
 
+var commandNames = map[CommandToken]string{
+	CmdBegin:     "begin-design",
+	CmdExec:      "exec",
+	CmdDir:       "dir",
+	CmdCopy:      "copy",
+	CmdGet:       "get",
+	CmdModule:    "module",
+	CmdWorkspace: "workspace",
+	CmdGitInit:   "git-init",
+	CmdMain:      "main",
+	CmdPackage:   "package",
+	CmdTest:      "test",
+	CmdLicense:   "license",
+}
+
+// String returns the design keyword of the command without its colon
+func (c CommandToken) String() string {
+	if n, ok := commandNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("CommandToken(%d)", int(c))
+}
+
 type astQueue struct {
 	q []astNode
 }
@@ -73,6 +108,7 @@ func (s *nestStack) pop() nestLevel {
 
 type astNode struct {
 	nest nestLevel
+	line int // line number in the design, counting from 1
 	cmd  CommandToken
 	//tag       string
 	cmdParams any
@@ -80,6 +116,7 @@ type astNode struct {
 
 type designParser struct {
 	text    []string
+	src     []string // text as written, before its variables are expanded
 	line    int
 	errs    []error
 	project string
@@ -87,6 +124,25 @@ type designParser struct {
 	regexs  map[string]*regexp.Regexp
 	ast     astQueue
 	nests   nestStack
+	pkgs    map[string]string                 // package name declared for each directory
+	vars    map[string]string                 // variables expanded in text
+	cfg     config                            // user settings supplying variables and defaults
+	root    string                            // directory the design is executed in
+	fs      FS                                // filesystem the design is executed against
+	runner  Runner                            // runs the external commands of the design
+	events  func(Event)                       // receives the progress of execution, may be nil
+	out     io.Writer                         // output of the commands of the running step
+	logf    logFunc                           // receives info and debug messages, may be nil
+	ask     func(name string) (string, error) // answers variables conditions use, may be nil
+	answers map[string]string                 // what ask answered
+}
+
+// parseOptions are the ways of reading a design beyond its text
+type parseOptions struct {
+	vars map[string]string                 // variables given on the command line
+	ask  func(name string) (string, error) // answers variables conditions use
+	logf logFunc                           // receives info and debug messages
+	fs   FS                                // filesystem the tests of if: look at, the disk when nil
 }
 
 func (d *designParser) current() (string, error) {
@@ -122,9 +178,6 @@ func (d *designParser) Errors() string {
 }
 
 func (d *designParser) nesting(dir, lim int, path ...path.AbsPath) {
-	//var trace = trace.New(os.Stderr)                             //<rmv/>
-	//trace.Trace("----------------------entering nesting\n")      //<rmv/>
-	//defer trace.Trace("----------------------leaving nesting\n") //<rmv/>
 
 	if dir > 0 {
 		if len(path) == 0 {
@@ -133,10 +186,11 @@ func (d *designParser) nesting(dir, lim int, path ...path.AbsPath) {
 		}
 		d.nests.push(d.nest)
 		d.nest = nestLevel{limit: lim, nest: d.nest.nest + 1, path: path[0]}
-		//trace.Trace("new nesting level ", d.nest.nest, " limit ", d.nest.limit, " path ", path) //<rmv/>
+		d.debugf("nesting level %d in %s until line %d", d.nest.nest, d.nest.path, lim)
 	}
 	if dir < 0 {
 		d.nest = d.nests.pop()
+		d.debugf("back to nesting level %d at line %d", d.nest.nest, d.line+1)
 	}
 }
 func (d *designParser) hasErrors() bool {
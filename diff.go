@@ -0,0 +1,357 @@
+package goproject
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitfield/script"
+	path "github.com/rhysd/abspath"
+	"github.com/westarver/helper"
+)
+
+const (
+	DriftCreate = "create"  // the design would create it
+	DriftExists = "exists"  // present and the same as the design
+	DriftDiffer = "differs" // present but not what the design produces
+	DriftSkip   = "skipped" // cannot be known without executing the design
+)
+
+// DiffContext is the number of unchanged lines shown around each hunk
+const DiffContext = 3
+
+// driftEntry is one thing a design produces compared with what is on disk.
+type driftEntry struct {
+	Status string `json:"status"`
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Detail string `json:"detail,omitempty"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+type drift struct {
+	Design  string       `json:"design"`
+	Dir     string       `json:"dir"`
+	Create  int          `json:"create"`
+	Exists  int          `json:"exists"`
+	Differ  int          `json:"differ"`
+	Entries []driftEntry `json:"entries"`
+}
+
+//─────────────┤ diffDesign ├─────────────
+
+// diffDesign compares what the parsed design would create against the
+// existing project in dir. The design is treated as if it were executed
+// in the parent of dir, so a root of dir: ${project} lands on dir itself.
+func diffDesign(desn, dir string, p *designParser) (drift, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return drift{}, err
+	}
+	if !helper.DirExists(abs) {
+		return drift{}, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	wd, err := path.Getwd()
+	if err != nil {
+		return drift{}, err
+	}
+	base := filepath.Dir(abs)
+	target := func(p string) string {
+		return filepath.Join(base, filepath.FromSlash(relPath(wd.String(), p)))
+	}
+
+	d := drift{Design: desn, Dir: abs, Entries: []driftEntry{}}
+	for _, n := range p.ast.q {
+		dest := target(n.nest.path.String())
+		add := func(status, kind, file, detail string) {
+			d.add(driftEntry{Status: status, Kind: kind, Path: relPath(base, file), Line: n.line, Detail: detail})
+		}
+
+		switch prm := n.cmdParams.(type) {
+		case path.AbsPath:
+			d.diffDir(n.line, base, target(prm.String()))
+		case workspaceParams:
+			var use []string
+			for _, m := range prm.use {
+				if rel, err := workspaceRel(n.nest.path.String(), m); err == nil {
+					use = append(use, rel)
+				}
+			}
+			d.diffFile(n.line, base, filepath.Join(dest, "go.work"), []byte(prm.goWork(use)))
+		case gitInitParams:
+			if _, err := os.Stat(filepath.Join(dest, ".git")); err == nil {
+				add(DriftExists, "git", filepath.Join(dest, ".git"), "")
+			} else {
+				add(DriftCreate, "git", filepath.Join(dest, ".git"), "")
+			}
+		case sourceParams:
+			src, err := renderSource(dest, prm)
+			if err != nil {
+				return d, fmt.Errorf("error generating %s: %v", prm.file, err)
+			}
+			d.diffFile(n.line, base, filepath.Join(dest, prm.file), src)
+		case licenseParams:
+			txt, err := renderLicense(prm, p.project)
+			if err != nil {
+				return d, fmt.Errorf("unknown license %s", prm.id)
+			}
+			d.diffFile(n.line, base, filepath.Join(dest, "LICENSE"), txt)
+		case customParams:
+			add(DriftSkip, prm.d.Keyword(), dest, prm.d.Describe(prm.params))
+		case string:
+			switch n.cmd {
+			case CmdModule:
+				d.diffModule(n.line, base, filepath.Join(dest, "go.mod"), prm)
+			case CmdCopy:
+				d.diffCopy(n.line, base, dest, prm)
+			default:
+				add(DriftSkip, n.cmd.String(), dest, prm)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func (d *drift) add(e driftEntry) {
+	switch e.Status {
+	case DriftCreate:
+		d.Create++
+	case DriftExists:
+		d.Exists++
+	case DriftDiffer:
+		d.Differ++
+	}
+	d.Entries = append(d.Entries, e)
+}
+
+//─────────────┤ diffDir ├─────────────
+
+func (d *drift) diffDir(line int, base, dir string) {
+	e := driftEntry{Status: DriftCreate, Kind: "dir", Path: relPath(base, dir), Line: line}
+	if info, err := os.Stat(dir); err == nil {
+		e.Status = DriftExists
+		if !info.IsDir() {
+			e.Status = DriftDiffer
+			e.Detail = "is a file"
+		}
+	}
+	d.add(e)
+}
+
+//─────────────┤ diffModule ├─────────────
+
+func (d *drift) diffModule(line int, base, file, mod string) {
+	e := driftEntry{Status: DriftCreate, Kind: "module", Path: relPath(base, file), Line: line, Detail: mod}
+	if _, err := os.Stat(file); err == nil {
+		e.Status = DriftExists
+		if have := goModPath(file); have != mod {
+			e.Status = DriftDiffer
+			e.Detail = fmt.Sprintf("module %s, the design has %s", have, mod)
+		}
+	}
+	d.add(e)
+}
+
+//─────────────┤ diffCopy ├─────────────
+
+// diffCopy follows the rules of the copy: directive, a directory source is
+// copied flat into dest, an absolute file keeps only its base name and a
+// relative one keeps its path.
+func (d *drift) diffCopy(line int, base, dest, src string) {
+	var files [][2]string
+	if helper.DirExists(src) {
+		slice, _ := script.FindFiles(src).Slice()
+		for _, s := range slice {
+			files = append(files, [2]string{s, filepath.Join(dest, filepath.Base(s))})
+		}
+	} else {
+		for _, s := range copySources(src) {
+			dst := filepath.Join(dest, s)
+			if filepath.IsAbs(s) {
+				dst = filepath.Join(dest, filepath.Base(s))
+			}
+			files = append(files, [2]string{s, dst})
+		}
+	}
+
+	for _, f := range files {
+		want, err := os.ReadFile(f[0])
+		if err != nil {
+			d.add(driftEntry{Status: DriftSkip, Kind: "file", Path: relPath(base, f[1]), Line: line, Detail: "copy source " + f[0] + " does not exist"})
+			continue
+		}
+		d.diffFile(line, base, f[1], want)
+	}
+}
+
+//─────────────┤ diffFile ├─────────────
+
+func (d *drift) diffFile(line int, base, file string, want []byte) {
+	rel := relPath(base, file)
+	e := driftEntry{Status: DriftCreate, Kind: "file", Path: rel, Line: line}
+
+	have, err := os.ReadFile(file)
+	if err == nil {
+		e.Status = DriftExists
+		if !bytes.Equal(have, want) {
+			e.Status = DriftDiffer
+			e.Diff = unifiedDiff(rel+" (existing)", rel+" (design)", string(have), string(want))
+		}
+	}
+	d.add(e)
+}
+
+//─────────────┤ format ├─────────────
+
+func (d drift) format(form string) (string, error) {
+	switch form {
+	case "", "text":
+		var b strings.Builder
+		for _, e := range d.Entries {
+			fmt.Fprintf(&b, "%-8s %-10s %s", e.Status, e.Kind, e.Path)
+			if e.Detail != "" {
+				fmt.Fprintf(&b, ": %s", e.Detail)
+			}
+			b.WriteString("\n")
+			b.WriteString(e.Diff)
+		}
+		fmt.Fprintf(&b, "%s: %d to create, %d existing, %d differ\n", d.Dir, d.Create, d.Exists, d.Differ)
+		return b.String(), nil
+	case "json":
+		return marshalJSON(d)
+	}
+
+	return "", fmt.Errorf("unknown format %s, use text or json", form)
+}
+
+//─────────────┤ exitCode ├─────────────
+
+func (d drift) exitCode() int {
+	if d.Create > 0 || d.Differ > 0 {
+		return ExitDesignDrift
+	}
+	return 0
+}
+
+//─────────────┤ unifiedDiff ├─────────────
+
+// unifiedDiff returns the differences between a and b in unified format,
+// an empty string when they are the same.
+func unifiedDiff(aName, bName, a, b string) string {
+	al, bl := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of al[i:] and bl[j:]
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			switch {
+			case al[i] == bl[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] > lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type op struct {
+		kind byte // ' ', '-' or '+'
+		text string
+		a, b int // line index in a and b before this op
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			ops = append(ops, op{' ', al[i], i, j})
+			i++
+			j++
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', al[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', bl[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+
+		// grow the hunk while changes are closer than two contexts apart
+		start := k - DiffContext
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*DiffContext {
+				end += DiffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		var na, nb int
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				na++
+			}
+			if o.kind != '-' {
+				nb++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ops[start].a, na), hunkRange(ops[start].b, nb))
+		for _, o := range ops[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", o.kind, o.text)
+		}
+		k = end
+	}
+
+	return out.String()
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
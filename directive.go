@@ -0,0 +1,152 @@
+package goproject
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// Directive is a design keyword added by a program that embeds go-project.
+// Once registered it can be used in designs like the built-in directives,
+// on one line or with its text enclosed in parentheses over several lines.
+type Directive interface {
+	// Keyword is the name used in designs, without the colon
+	Keyword() string
+	// Parse turns the text following the keyword into the parameters of a
+	// step. Text spread over several lines keeps its newlines.
+	Parse(text string) (any, error)
+	// Validate checks the parameters without executing anything
+	Validate(params any) error
+	// Execute carries out the step in env.Dir, the directory of the
+	// enclosing block, going through env for files and commands
+	Execute(ctx context.Context, env Env, params any) error
+	// Describe tells what Execute would do, for plan and diff
+	Describe(params any) string
+}
+
+// Env is what a registered directive executes against, the same as the
+// built-in directives of the design. Writing files through FS and running
+// commands through Runner keeps a directive within a MemFS, an OverlayFS or
+// a RecordingRunner given to Execute.
+type Env struct {
+	// Dir is the directory of the enclosing block
+	Dir string
+	// FS receives the files the step writes
+	FS FS
+	// Runner runs the commands of the step
+	Runner Runner
+	// Out receives the output of the step, written as NodeOutput events
+	// when events are delivered
+	Out io.Writer
+}
+
+// keywords a registered directive may use
+var directiveKeyword = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// directives registered with Register by their keyword
+var directives = map[string]Directive{}
+
+// customParams are the parameters of a step of a registered directive
+type customParams struct {
+	d      Directive
+	params any
+}
+
+//─────────────┤ Register ├─────────────
+
+// Register adds d to the directives designs may use. It is meant to be
+// called before Run or Parse, typically from an init function. A keyword
+// that is taken, by a built-in directive or by an earlier Register, is an
+// error.
+func Register(d Directive) error {
+	kw := d.Keyword()
+	if !directiveKeyword.MatchString(kw) {
+		return fmt.Errorf("invalid directive keyword %q, use lower case letters, digits and dashes", kw)
+	}
+	if isBuiltinKeyword(kw) {
+		return fmt.Errorf("directive %s is built in", kw)
+	}
+	if _, ok := directives[kw]; ok {
+		return fmt.Errorf("directive %s is already registered", kw)
+	}
+
+	directives[kw] = d
+	return nil
+}
+
+// isBuiltinKeyword reports whether kw is a keyword of the design language
+// or a key of the yaml, toml and json designs
+func isBuiltinKeyword(kw string) bool {
+	switch kw {
+	case "project", "name", "end-design", "if", "else", "comment", "steps", "header", "footer":
+		return true
+	}
+	for _, n := range commandNames {
+		if n == kw {
+			return true
+		}
+	}
+	return false
+}
+
+// registeredKeywords are the keywords of the registered directives in order
+func registeredKeywords() []string {
+	var kws []string
+	for kw := range directives {
+		kws = append(kws, kw)
+	}
+	sort.Strings(kws)
+	return kws
+}
+
+// matches the keyword at the start of a design line
+var lineKeyword = regexp.MustCompile(`^\s*([a-z][a-z0-9-]*):`)
+
+// lookupDirective returns the registered directive whose keyword starts ln
+func lookupDirective(ln string) (Directive, bool) {
+	m := lineKeyword.FindStringSubmatch(ln)
+	if m == nil {
+		return nil, false
+	}
+	d, ok := directives[m[1]]
+	return d, ok
+}
+
+//─────────────┤ keyword ├─────────────
+
+// keyword is the design keyword of the node, that of its directive for a
+// registered one
+func (n astNode) keyword() string {
+	if cp, ok := n.cmdParams.(customParams); ok {
+		return cp.d.Keyword()
+	}
+	return n.cmd.String()
+}
+
+//<rgn scanCustom>
+//─────────────┤ scanCustom ├─────────────
+
+// scanCustom returns the scanner of a registered directive
+func scanCustom(dr Directive) scanfunc {
+	return func(d *designParser) scanfunc {
+		startLn := d.line
+		cur, n, ok := directiveText(d, `^\s*`+regexp.QuoteMeta(dr.Keyword())+`:`)
+		if !ok {
+			return nil
+		}
+
+		d.debugf("line %d: %s: %s", startLn+1, dr.Keyword(), cur)
+		params, err := dr.Parse(cur)
+		if err != nil {
+			d.setError(fmt.Errorf("%s: %v at line %d", dr.Keyword(), err, startLn+1))
+			d.line += n
+			return scanCurrentLevel
+		}
+
+		d.ast.push(astNode{nest: d.nest, line: startLn + 1, cmd: CmdCustom, cmdParams: customParams{d: dr, params: params}})
+		d.line += n
+		return scanCurrentLevel
+	}
+} //</rgn scanCustom>
@@ -0,0 +1,64 @@
+package goproject
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// EventKind tells what an Event reports
+type EventKind string
+
+const (
+	PlanReady    EventKind = "plan-ready"    // Nodes holds the steps about to run
+	NodeStarted  EventKind = "node-started"  // a step begins
+	NodeOutput   EventKind = "node-output"   // Output holds what a command of the step wrote
+	NodeFinished EventKind = "node-finished" // Status, Duration and Err tell how the step went
+	RunFinished  EventKind = "run-finished"  // Statuses holds the outcome of every step
+)
+
+// Event reports the progress of Design.Execute and of init. Only the fields
+// named for its kind are set.
+type Event struct {
+	Kind     EventKind
+	Time     time.Time
+	Index    int   // index of the step in Nodes, -1 for events of the whole run
+	Node     *Node // the step, for the node events
+	Nodes    []Node
+	Output   string
+	Status   NodeStatus
+	Statuses []NodeStatus
+	Duration time.Duration
+	Err      error
+}
+
+// emit hands e to the listener of p, if there is one
+func (p *designParser) emit(e Event) {
+	if p.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	p.events(e)
+}
+
+// nodeOutput is where the commands of step i write. Without a listener it
+// is stdout, otherwise every write becomes a NodeOutput event.
+func (p *designParser) nodeOutput(i int, n *Node) io.Writer {
+	if p.events == nil {
+		return os.Stdout
+	}
+	return &nodeWriter{p: p, index: i, node: n}
+}
+
+// nodeWriter turns the output of a step into events. It is a pointer so
+// that exec gives it the output and errors of a command from one goroutine.
+type nodeWriter struct {
+	p     *designParser
+	index int
+	node  *Node
+}
+
+func (w *nodeWriter) Write(b []byte) (int, error) {
+	w.p.emit(Event{Kind: NodeOutput, Index: w.index, Node: w.node, Output: string(b)})
+	return len(b), nil
+}
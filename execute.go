@@ -1,41 +1,182 @@
 package goproject
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
-	"github.com/bitfield/script"
+	"bitbucket.org/creachadair/shell"
 	path "github.com/rhysd/abspath"
-	"github.com/westarver/helper"
+)
+
+// NodeStatus tells what became of a step of a design
+type NodeStatus string
+
+const (
+	NodeDone        NodeStatus = "done"
+	NodeFailed      NodeStatus = "failed"
+	NodeInterrupted NodeStatus = "interrupted" // cancelled while it ran
+	NodeNotRun      NodeStatus = "not run"
 )
 
 //─────────────┤ executeAst ├─────────────
 
-func executeAst(p *designParser) error {
-	//var trace = trace.New(os.Stderr) //<rmv/>
-	//trace.Trace("----------------------------entering executeAst")      //<rmv/>
-	//defer trace.Trace("----------------------------leaving executeAst") //<rmv/>
-	for _, n := range p.ast.q {
-		//trace.Trace("executing node ", i, " ", n) //<rmv/>
-		runCommand(p, n)
+// executeAst carries out the steps of the design in order. Once ctx is
+// done, or a step has failed and stopOnError is set, the remaining steps
+// are not run. Commands still running when ctx is done are killed. The
+// progress is reported to p.events when it is set.
+func executeAst(ctx context.Context, p *designParser, stopOnError bool) execSummary {
+	start := time.Now()
+	sum := execSummary{status: make([]NodeStatus, len(p.ast.q))}
+	for i := range sum.status {
+		sum.status[i] = NodeNotRun
 	}
-	return nil
+	nodes := makePlan("", p).Nodes
+	took := make([]time.Duration, len(p.ast.q))
+	p.emit(Event{Kind: PlanReady, Index: -1, Nodes: nodes})
+
+	// a step is finished once its initial commit, if any, is made
+	commits := func(i int) bool {
+		gp, ok := p.ast.q[i].cmdParams.(gitInitParams)
+		return ok && gp.commit && sum.status[i] == NodeDone
+	}
+	finished := func(i int, err error) {
+		p.emit(Event{Kind: NodeFinished, Index: i, Node: &nodes[i], Status: sum.status[i], Duration: took[i], Err: err})
+	}
+
+	for i, n := range p.ast.q {
+		if ctx.Err() != nil || (stopOnError && sum.count(NodeFailed) > 0) {
+			break
+		}
+		p.emit(Event{Kind: NodeStarted, Index: i, Node: &nodes[i]})
+		p.out = p.nodeOutput(i, &nodes[i])
+		errs := len(p.errs)
+		t := time.Now()
+		err := runCommand(ctx, p, n)
+		took[i] = time.Since(t)
+		sum.status[i] = nodeStatus(ctx, err != nil || len(p.errs) > errs)
+		if !commits(i) {
+			finished(i, stepError(p, errs, err))
+		}
+	}
+
+	// initial commits wait until everything the design generates exists,
+	// a failed commit fails its git-init: step
+	for i, n := range p.ast.q {
+		if !commits(i) {
+			continue
+		}
+		var err error
+		if ctx.Err() == nil {
+			p.out = p.nodeOutput(i, &nodes[i])
+			errs := len(p.errs)
+			t := time.Now()
+			err = commitGitRepo(ctx, p, n.nest.path.String(), n.cmdParams.(gitInitParams))
+			took[i] += time.Since(t)
+			sum.status[i] = nodeStatus(ctx, err != nil)
+			err = stepError(p, errs, err)
+		}
+		finished(i, err)
+	}
+
+	sum.cancelled = ctx.Err() != nil
+	p.emit(Event{Kind: RunFinished, Index: -1, Statuses: sum.status, Duration: time.Since(start), Err: ctx.Err()})
+	return sum
+}
+
+// stepError is the first error a step reported to p, or err when it
+// reported none
+func stepError(p *designParser, errs int, err error) error {
+	if len(p.errs) > errs {
+		return p.errs[errs]
+	}
+	return err
+}
+
+func nodeStatus(ctx context.Context, failed bool) NodeStatus {
+	switch {
+	case !failed:
+		return NodeDone
+	case ctx.Err() != nil:
+		return NodeInterrupted
+	}
+	return NodeFailed
+}
+
+//─────────────┤ execSummary ├─────────────
+
+// execSummary holds the status of each step of an executed design
+type execSummary struct {
+	status    []NodeStatus
+	cancelled bool // execution was cut short by its context
+}
+
+func (s execSummary) count(st NodeStatus) int {
+	n := 0
+	for _, x := range s.status {
+		if x == st {
+			n++
+		}
+	}
+	return n
+}
+
+// exitCode is ExitInterrupted after a cancellation, ExitExecution when
+// nothing succeeded and ExitPartial when only some steps failed
+func (s execSummary) exitCode() int {
+	switch {
+	case s.cancelled:
+		return ExitInterrupted
+	case s.count(NodeFailed) == 0:
+		return ExitOK
+	case s.count(NodeDone) == 0:
+		return ExitExecution
+	}
+	return ExitPartial
+}
+
+func (s execSummary) String() string {
+	str := fmt.Sprintf("%d steps, %d done, %d failed", len(s.status), s.count(NodeDone), s.count(NodeFailed))
+	if n := s.count(NodeInterrupted); n > 0 {
+		str += fmt.Sprintf(", %d interrupted", n)
+	}
+	if n := s.count(NodeNotRun); n > 0 {
+		str += fmt.Sprintf(", %d not run", n)
+	}
+	return str
+}
+
+// report lists every step of p with its status
+func (s execSummary) report(p *designParser) string {
+	var b strings.Builder
+	for i, n := range p.ast.q {
+		fmt.Fprintf(&b, "%-11s line %-4d %s: in %s\n", s.status[i], n.line, n.keyword(), relPath(p.root, n.nest.path.String()))
+	}
+	return b.String()
 }
 
 //─────────────┤ runCommand ├─────────────
 
-func runCommand(p *designParser, an astNode) error {
-	//var trace = trace.New(os.Stderr) //<rmv/>
+func runCommand(ctx context.Context, p *designParser, an astNode) error {
 
 	switch an.cmd {
 	case CmdExec:
-		execCmd(an)
+		p.infof("exec %s  [in %s]", an.cmdParams.(string), an.nest.path)
+		if err := execCmd(ctx, p.runner, p.fs, p.out, an); err != nil {
+			p.setError(fmt.Errorf("error running %s: %v", an.cmdParams.(string), err))
+			return err
+		}
 	case CmdDir:
 		dir := an.cmdParams.(path.AbsPath).String()
-		err := os.MkdirAll(dir, 0777)
+		p.infof("mkdir %s", dir)
+		err := p.fs.MkdirAll(dir, 0777)
 		if err != nil {
 			p.setError(fmt.Errorf("error creating directory %s", dir))
 			return err
@@ -46,128 +187,56 @@ func runCommand(p *designParser, an astNode) error {
 		// src could be a single file, a list of files or a directory
 		src := an.cmdParams.(string)
 
-		if err, ok := copyDir(dest, src); ok {
-			//trace.Trace("is src dir? ", err) //<rmv/>
+		if err, ok := copyDir(p, dest, src); ok {
 			return err
 		}
 		// split the source string into individual names respecting quoted strings
 		slice := splitWithQuotes(src)
 		for _, s := range slice {
-			s = strings.Trim(s, "\t ")
+			s = strings.Trim(s, "\t \"")
 			if len(s) == 0 {
 				continue
 			}
-			//trace.Trace("source ", s) //<rmv/>
 			dst := filepath.Join(dest, s)
-			//trace.Trace("dest ", dst) //<rmv/>
-			err := CopyFileStr(dst, s)
+			if filepath.IsAbs(s) {
+				dst = filepath.Join(dest, filepath.Base(s))
+			}
+			p.infof("copy %s -> %s", s, dst)
+			err := copyFile(p.fs, dst, s)
 			if err != nil {
 				p.setError(fmt.Errorf("error copying %s to %s", s, dest))
-				//trace.Trace(err) //<rmv/>
+				p.debugf("copy %s: %v", s, err)
 				return err
 			}
 		}
 	case CmdGet:
-		wd, err := path.Getwd()
-		if err != nil {
-			return err
-		}
-		//trace.Trace("original wd ", wd.String()) //<rmv/>
-		err = os.Chdir(an.nest.path.String())
+		p.infof("wget %s  [in %s]", an.cmdParams.(string), an.nest.path)
+		err := execContext(ctx, p.runner, an.nest.path.String(), "wget "+an.cmdParams.(string), p.out)
 		if err != nil {
-			p.setError(fmt.Errorf("error downloading URL %s", an.cmdParams.(string)))
+			p.setError(fmt.Errorf("error downloading URL %s: %v", an.cmdParams.(string), err))
 			return err
 		}
-
-		//cd, _ := path.Getwd()  //<rmv/>
-		//trace.Trace("new wd ", cd.String())                //<rmv/>
-		//trace.Trace("downloading ", an.cmdParams.(string)) //<rmv/>
-		pipe := script.Exec("wget " + an.cmdParams.(string))
-		if pipe.Error() != nil {
-			p.setError(pipe.Error())
-		}
-		pipe.Stdout()
-
-		err = os.Chdir(wd.String())
-		if err != nil {
-			p.setError(fmt.Errorf("error downloading %s", an.cmdParams.(string)))
-			return err
-		}
-
 	case CmdModule:
-		wd, err := path.Getwd()
+		p.infof("go mod init %s  [in %s]", an.cmdParams.(string), an.nest.path)
+		err := execContext(ctx, p.runner, an.nest.path.String(), "go mod init "+an.cmdParams.(string), p.out)
 		if err != nil {
-			return err
-		}
-		//trace.Trace("original wd ", wd.String()) //<rmv/>
-		err = os.Chdir(an.nest.path.String())
-		if err != nil {
-			p.setError(fmt.Errorf("error initializing module %s", an.cmdParams.(string)))
-			return err
-		}
-
-		//cd, _ := path.Getwd()  //<rmv/>
-		//trace.Trace("new wd ", cd.String()) //<rmv/>
-
-		pipe := script.Exec("go mod init " + an.cmdParams.(string))
-		if pipe.Error() != nil {
-			p.setError(pipe.Error())
-		}
-		pipe.Stdout()
-
-		err = os.Chdir(wd.String())
-		if err != nil {
-			p.setError(fmt.Errorf("error initializing module %s", an.cmdParams.(string)))
+			p.setError(fmt.Errorf("error initializing module %s: %v", an.cmdParams.(string), err))
 			return err
 		}
 	case CmdWorkspace:
-		wd, err := path.Getwd()
-		if err != nil {
-			return err
-		}
-		//trace.Trace("initial wd ", wd.String()) //<rmv/>
-		err = os.Chdir(an.nest.path.String())
-		if err != nil {
-			p.setError(fmt.Errorf("error initializing workspace %s", an.cmdParams.(string)))
-			return err
-		}
-		//cd, _ := path.Getwd()   //<rmv/>
-		//trace.Trace("changed wd ", cd.String()) //<rmv/>
-		mods := strings.Split(an.cmdParams.(string), " ")
-		if len(mods) > 0 {
-			pipe := script.Exec("go work init " + mods[0])
-			if pipe.Error() != nil {
-				p.setError(fmt.Errorf("error initializing workspace %s", an.cmdParams.(string)))
-				return pipe.Error()
-			}
-			pipe.Stdout()
-			for _, m := range mods[1:] {
-				pipe := script.Exec("go work use " + m)
-				pipe.Stdout()
-			}
-		}
-
-		err = os.Chdir(wd.String())
-		if err != nil {
-			p.setError(fmt.Errorf("error initializing workspace %s", an.cmdParams.(string)))
-			return err
-		}
+		return writeWorkspace(p, an.nest.path.String(), an.cmdParams.(workspaceParams))
 	case CmdGitInit:
-		wd, err := path.Getwd()
-		if err != nil {
-			return err
-		}
-
-		err = os.Chdir(an.nest.path.String())
-		if err != nil {
-			p.setError(fmt.Errorf("error initializing git repo"))
-			return err
-		}
-
-		_, _ = script.Exec("git init").Stdout()
-		err = os.Chdir(wd.String())
-		if err != nil {
-			p.setError(fmt.Errorf("error initializing git repo"))
+		return initGitRepo(ctx, p, an.nest.path.String(), an.cmdParams.(gitInitParams))
+	case CmdMain, CmdPackage, CmdTest:
+		return writeSource(p, an.nest.path.String(), an.cmdParams.(sourceParams))
+	case CmdLicense:
+		return writeLicense(p, an.nest.path.String(), an.cmdParams.(licenseParams))
+	case CmdCustom:
+		cp := an.cmdParams.(customParams)
+		p.infof("%s: %s  [in %s]", cp.d.Keyword(), cp.d.Describe(cp.params), an.nest.path)
+		env := Env{Dir: an.nest.path.String(), FS: p.fs, Runner: p.runner, Out: p.out}
+		if err := cp.d.Execute(ctx, env, cp.params); err != nil {
+			p.setError(fmt.Errorf("error running %s at line %d: %v", cp.d.Keyword(), an.line, err))
 			return err
 		}
 
@@ -178,9 +247,11 @@ func runCommand(p *designParser, an astNode) error {
 
 //─────────────┤ execCmd ├─────────────
 
-func execCmd(an astNode) error {
-	redir := false
-	mode := os.O_RDWR | os.O_CREATE
+// execCmd has r run the command of an exec: in the directory of its block.
+// Its output goes to out unless it is redirected to a file, which is written
+// through fsys.
+func execCmd(ctx context.Context, r Runner, fsys FS, out io.Writer, an astNode) error {
+	var appnd bool
 	var file string
 
 	arg := an.cmdParams.(string)
@@ -191,58 +262,64 @@ func execCmd(an astNode) error {
 	if loc != nil {
 		last := loc[len(loc)-1]
 		mat := strings.Trim(arg[last[0]:last[1]], "\t ")
-		if len(mat) == 2 {
-			mode |= os.O_APPEND
-		}
-		redir = true
-		file = arg[last[1]:]
+		appnd = len(mat) == 2
+		file = strings.TrimSpace(arg[last[1]:])
 		arg = arg[:last[0]]
 	}
 	// end of dirty hack
 
-	p := script.Exec(arg)
-	if redir {
-		j := filepath.Join(an.nest.path.String(), file)
-		if mode&os.O_APPEND == 0 {
-			p.WriteFile(j)
-		} else {
-			p.AppendFile(j)
+	if file == "" {
+		return execContext(ctx, r, an.nest.path.String(), arg, out)
+	}
+
+	var buf bytes.Buffer
+	if err := execContext(ctx, r, an.nest.path.String(), arg, &buf); err != nil {
+		return err
+	}
+	file = filepath.Join(an.nest.path.String(), file)
+	data := buf.Bytes()
+	if appnd {
+		old, err := fsys.ReadFile(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
 		}
-	} else {
-		p.Stdout()
+		data = append(old, data...)
 	}
-	if p.Error() != nil {
-		return p.Error()
+	return fsys.WriteFile(file, data, 0666)
+}
+
+//─────────────┤ execContext ├─────────────
+
+// execContext has r run command in dir with its output going to w
+func execContext(ctx context.Context, r Runner, dir, command string, w io.Writer) error {
+	args, ok := shell.Split(command)
+	if !ok {
+		return fmt.Errorf("unbalanced quotes or backslashes in [%s]", command)
 	}
-	return nil
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+	return r.Run(ctx, Command{Dir: dir, Args: args}, w)
 }
 
 //─────────────┤ copyDir ├─────────────
 
-func copyDir(dst, src string) (error, bool) {
-	//var trace = trace.New(os.Stderr)                                   //<rmv/>
-	//trace.Trace("----------------------------entering copyDir\n")      //<rmv/>
-	//defer trace.Trace("----------------------------leaving copyDir\n") //<rmv/>
-	//trace.Trace("dest ", dst)                                          //<rmv/>
-	//trace.Trace("src ", src)                                           //<rmv/>
-	exist := helper.DirExists(dst)
-	if !exist {
-		err := os.MkdirAll(dst, 0777)
+func copyDir(p *designParser, dst, src string) (error, bool) {
+	if !isDir(p.fs, dst) {
+		err := p.fs.MkdirAll(dst, 0777)
 		if err != nil {
 			return err, false
 		}
 	}
-	exist = helper.DirExists(src)
-	if exist {
-		slice, err := script.FindFiles(src).Slice()
+	if isDir(p.fs, src) {
+		slice, err := findFiles(p.fs, src)
 		if err != nil {
 			return err, false
 		}
 		for _, sl := range slice {
 			dest := filepath.Join(dst, filepath.Base(sl))
-			//trace.Trace("dest ", dest) //<rmv/>
-			//trace.Trace("line ", sl)   //<rmv/>
-			err := CopyFileStr(dest, sl)
+			p.infof("copy %s -> %s", sl, dest)
+			err := copyFile(p.fs, dest, sl)
 			if err != nil {
 				return err, false
 			}
@@ -277,25 +354,8 @@ func splitWithQuotes(s string) []string {
 
 //─────────────┤ CopyFileStr ├─────────────
 
+// CopyFileStr copies the file src to dst on disk, making the directory of
+// dst when it is missing
 func CopyFileStr(dst, src string) error {
-	src, err := helper.ValidatePath(src)
-	if err != nil {
-		return err
-	}
-	s, err := os.ReadFile(src)
-	if err != nil {
-		return err
-	}
-
-	dst, err = helper.ValidatePath(dst)
-	if err != nil {
-		return err
-	}
-
-	err = os.WriteFile(dst, s, 0666)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return copyFile(OSFS{}, dst, src)
 }
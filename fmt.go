@@ -0,0 +1,255 @@
+package goproject
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// FmtIndent is the indentation of each nesting level in a formatted design
+const FmtIndent = "    "
+
+// keywords whose spacing fmt normalizes
+var fmtKeywords = regexp.MustCompile(`^(begin-design|end-design|project|name|exec|dir|copy|get|module|workspace|git-init|main|package|test|license|if|else):\s*(.*)$`)
+
+//─────────────┤ formatDesign ├─────────────
+
+// formatDesign returns the canonical form of a design. Every line is
+// indented by its nesting depth, a block is opened at the end of its
+// directive and closed by a parenthesis on a line of its own, runs of
+// blank lines become one and comments are kept where they are. The lines
+// of a block of text, such as the script of exec:, keep their indentation
+// relative to the first of them. Formatting formatted text returns it
+// unchanged.
+func formatDesign(src string) (string, error) {
+	var out []string
+	depth := 0
+	blank := false
+
+	// the depth of the block of text being formatted, the indentation of
+	// its first line and what the current line has beyond that
+	body, base, extra := -1, "", ""
+	first := false
+	indent := func() string {
+		if body >= 0 && depth >= body {
+			return strings.Repeat(FmtIndent, body) + extra
+		}
+		return strings.Repeat(FmtIndent, depth)
+	}
+
+	emit := func(l string) {
+		if blank && len(out) > 0 && !strings.HasSuffix(out[len(out)-1], "(") && l != ")" {
+			out = append(out, "")
+		}
+		blank = false
+		if strings.HasPrefix(l, "begin-design:") || strings.HasPrefix(l, "end-design:") {
+			out = append(out, l)
+			return
+		}
+		out = append(out, indent()+l)
+	}
+	closeBlock := func(n int) error {
+		for ; n > 0; n-- {
+			if depth == 0 {
+				return fmt.Errorf("unbalanced parentheses at line %d", len(out)+1)
+			}
+			depth--
+			emit(")")
+			if depth < body {
+				body = -1
+			}
+		}
+		return nil
+	}
+
+	for i, raw := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		l := strings.TrimSpace(raw)
+		if l == "" {
+			blank = true
+			continue
+		}
+
+		if body >= 0 {
+			ws := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+			if first {
+				base, first = ws, false
+			}
+			extra = ""
+			if strings.HasPrefix(ws, base) {
+				extra = ws[len(base):]
+			}
+		}
+		if strings.HasPrefix(l, "#") {
+			emit(l)
+			continue
+		}
+
+		// parentheses that open a line close blocks before anything else
+		lead := len(l) - len(strings.TrimLeft(l, ") \t"))
+		if err := closeBlock(strings.Count(l[:lead], ")")); err != nil {
+			return "", fmt.Errorf("unbalanced parentheses at line %d", i+1)
+		}
+		l = l[lead:]
+		if l == "" {
+			continue
+		}
+
+		opens, closes := strings.Count(l, "("), strings.Count(l, ")")
+		trail := 0
+		for closes > opens && strings.HasSuffix(l, ")") {
+			l = strings.TrimSpace(strings.TrimSuffix(l, ")"))
+			closes--
+			trail++
+		}
+
+		if opens > closes && strings.HasSuffix(l, "(") {
+			l = strings.TrimSpace(strings.TrimSuffix(l, "("))
+			emit(normalizeDirective(l) + " (")
+			depth++
+			if kw, _, _ := strings.Cut(l, ":"); body < 0 && !hasSteps(kw) {
+				body, first = depth, true
+			}
+		} else if l != "" {
+			emit(normalizeDirective(l))
+		}
+		if err := closeBlock(trail); err != nil {
+			return "", fmt.Errorf("unbalanced parentheses at line %d", i+1)
+		}
+	}
+
+	if depth != 0 {
+		return "", fmt.Errorf("unbalanced parentheses, %d block(s) never closed", depth)
+	}
+	return strings.Join(out, "\n") + "\n", nil
+}
+
+//─────────────┤ normalizeDirective ├─────────────
+
+// normalizeDirective leaves a single space between a keyword and its text
+func normalizeDirective(l string) string {
+	m := fmtKeywords.FindStringSubmatch(l)
+	if m == nil {
+		dr, ok := lookupDirective(l)
+		if !ok {
+			return l
+		}
+		m = []string{l, dr.Keyword(), strings.TrimSpace(strings.SplitN(l, ":", 2)[1])}
+	}
+	if m[2] == "" {
+		return m[1] + ":"
+	}
+	return m[1] + ": " + m[2]
+}
+
+//─────────────┤ fmtFile ├─────────────
+
+// fmtFile formats one design file and checks that the formatted design
+// parses to the same steps as the original before anything is written.
+func fmtFile(file string) (string, string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", "", err
+	}
+	src := string(b)
+
+	// yaml, toml and json designs are formatted by writing them again
+	if f, _ := designFormat(file, ""); f != FormatNative {
+		out, err := convertDesign(src, f, f)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %v", file, err)
+		}
+		return src, out, nil
+	}
+
+	out, err := formatDesign(src)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %v", file, err)
+	}
+
+	before, err := parseDesign("", strings.Split(src, "\n"), parseOptions{ask: blankAnswer})
+	if err != nil {
+		return "", "", err
+	}
+	if before.hasErrors() {
+		return "", "", fmt.Errorf("%s: %s", file, before.Errors())
+	}
+	after, err := parseDesign("", strings.Split(out, "\n"), parseOptions{ask: blankAnswer})
+	if err != nil {
+		return "", "", err
+	}
+	if !sameSteps(makePlan(file, before), makePlan(file, after)) || after.hasErrors() {
+		return "", "", fmt.Errorf("%s: formatting would change the meaning of the design, left as it is", file)
+	}
+
+	return src, out, nil
+}
+
+func sameSteps(a, b plan) bool {
+	if len(a.Nodes) != len(b.Nodes) {
+		return false
+	}
+	for i := range a.Nodes {
+		x, y := a.Nodes[i], b.Nodes[i]
+		if x.Command != y.Command || x.Path != y.Path || !reflect.DeepEqual(trimParams(x.Params), trimParams(y.Params)) {
+			return false
+		}
+	}
+	return true
+}
+
+// trimParams drops the indentation of the lines of text parameters, which
+// formatting moves with the block holding them
+func trimParams(p map[string]any) map[string]any {
+	m := map[string]any{}
+	for k, v := range p {
+		if s, ok := v.(string); ok {
+			lines := strings.Split(s, "\n")
+			for i := range lines {
+				lines[i] = strings.TrimSpace(lines[i])
+			}
+			v = strings.Join(lines, "\n")
+		}
+		m[k] = v
+	}
+	return m
+}
+
+//─────────────┤ doFmt ├─────────────
+
+// doFmt formats each file. The formatted text is returned unless write or
+// diff is set, write rewrites the files that change and diff returns a
+// unified diff of them. changed reports whether any file was not already
+// formatted.
+func doFmt(files []string, write, diff bool) (out string, changed bool, err error) {
+	var b strings.Builder
+	for _, file := range files {
+		src, res, e := fmtFile(file)
+		if e != nil {
+			err = e
+			continue
+		}
+
+		if src != res {
+			changed = true
+		}
+		if diff {
+			b.WriteString(unifiedDiff(file+" (original)", file+" (formatted)", src, res))
+		}
+		if write && src != res {
+			info, e := os.Stat(file)
+			if e == nil {
+				e = os.WriteFile(file, []byte(res), info.Mode().Perm())
+			}
+			if e != nil {
+				err = fmt.Errorf("error writing %s", file)
+			}
+		}
+		if !write && !diff {
+			b.WriteString(res)
+		}
+	}
+
+	return b.String(), changed, err
+}
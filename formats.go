@@ -0,0 +1,584 @@
+package goproject
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// design formats, native is the line based syntax of .design files
+const (
+	FormatNative = "native"
+	FormatYAML   = "yaml"
+	FormatTOML   = "toml"
+	FormatJSON   = "json"
+)
+
+var designFormats = []string{FormatNative, FormatYAML, FormatTOML, FormatJSON}
+
+//─────────────┤ designFormat ├─────────────
+
+// designFormat is the format of the design file, override when it is given
+// and otherwise told by the extension: .yaml or .yml, .toml and .json. Any
+// other file is native.
+func designFormat(file, override string) (string, error) {
+	if override != "" {
+		for _, f := range designFormats {
+			if f == override {
+				return f, nil
+			}
+		}
+		return "", fmt.Errorf("unknown design format %s, use one of %s", override, strings.Join(designFormats, ", "))
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+	return FormatNative, nil
+}
+
+//─────────────┤ defaultDesign ├─────────────
+
+// defaultDesign is the design used without --design, go-project.design or
+// when there is none the first of its yaml, toml and json forms there is
+func defaultDesign() string {
+	for _, ext := range []string{"", ".yaml", ".yml", ".toml", ".json"} {
+		if _, err := os.Stat(DefaultCfgFile + ext); err == nil {
+			return DefaultCfgFile + ext
+		}
+	}
+	return DefaultCfgFile
+}
+
+//─────────────┤ designDoc ├─────────────
+
+// designDoc is a design as a tree, the shape of the yaml, toml and json
+// formats. Each step holds one directive, a dir block holds its steps.
+type designDoc struct {
+	Header string // text before begin-design:
+	Steps  []docStep
+	Footer string // text after end-design:
+}
+
+type docStep struct {
+	Keyword string    // directive, comment for a comment line
+	Text    string    // text following the keyword
+	Block   bool      // the directive opens a block
+	Lines   []string  // lines of a block other than that of dir
+	Steps   []docStep // steps of a dir, if or else block
+}
+
+//─────────────┤ designLines ├─────────────
+
+// designLines are the native lines of the design src written in format
+func designLines(src, format string) ([]string, error) {
+	if format == FormatNative || format == "" {
+		return strings.Split(src, "\n"), nil
+	}
+	doc, err := decodeDesign(src, format)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(doc.native(), "\n"), nil
+}
+
+//─────────────┤ convertDesign ├─────────────
+
+// convertDesign translates the design src from one format to another. The
+// result is parsed back and must have the same steps as src.
+func convertDesign(src, from, to string) (string, error) {
+	doc, err := decodeDesign(src, from)
+	if err != nil {
+		return "", err
+	}
+	out, err := doc.encode(to)
+	if err != nil {
+		return "", err
+	}
+
+	before, err := designLines(src, from)
+	if err != nil {
+		return "", err
+	}
+	after, err := designLines(out, to)
+	if err != nil {
+		return "", err
+	}
+	bp := parseDesignIn("", before, "/", config{}, parseOptions{ask: blankAnswer})
+	ap := parseDesignIn("", after, "/", config{}, parseOptions{ask: blankAnswer})
+	if !sameSteps(makePlan("", bp), makePlan("", ap)) || len(bp.errs) != len(ap.errs) {
+		return "", fmt.Errorf("the design cannot be converted to %s without changing its meaning", to)
+	}
+	return out, nil
+}
+
+// doConvert carries out the convert command, the design file is read in
+// the format from, told by its extension when empty, and returned in the
+// format to. The exit code goes with the error.
+func doConvert(file, from, to string) (string, int, error) {
+	if to == "" {
+		return "", ExitUsage, fmt.Errorf("convert needs --to with one of %s", strings.Join(designFormats, ", "))
+	}
+	to, err := designFormat("", to)
+	if err != nil {
+		return "", ExitUsage, err
+	}
+	from, err = designFormat(file, from)
+	if err != nil {
+		return "", ExitUsage, err
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", ExitDesignNotFound, fmt.Errorf("%w: %s", ErrDesignNotFound, file)
+	}
+	out, err := convertDesign(string(b), from, to)
+	if err != nil {
+		return "", ExitInvalidDesign, fmt.Errorf("%s: %v", file, err)
+	}
+	return out, ExitOK, nil
+}
+
+// decodeDesign reads src written in format into a designDoc
+func decodeDesign(src, format string) (*designDoc, error) {
+	var v any
+	var err error
+	switch format {
+	case FormatNative, "":
+		return docFromNative(src)
+	case FormatYAML:
+		v, err = parseYAML(src)
+	case FormatTOML:
+		v, err = parseTOML(src)
+	case FormatJSON:
+		dec := json.NewDecoder(strings.NewReader(src))
+		err = dec.Decode(&v)
+	default:
+		return nil, fmt.Errorf("unknown design format %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s design: %v", format, err)
+	}
+	doc, err := docFromValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s design: %v", format, err)
+	}
+	return doc, nil
+}
+
+// encode writes the design in format
+func (doc *designDoc) encode(format string) (string, error) {
+	switch format {
+	case FormatNative, "":
+		return doc.native(), nil
+	case FormatYAML:
+		return encodeYAML(doc.value()), nil
+	case FormatTOML:
+		return encodeTOML(doc.value()), nil
+	case FormatJSON:
+		var b bytes.Buffer
+		if err := writeJSON(&b, doc.value(), "  "); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("unknown design format %s", format)
+}
+
+//─────────────┤ docFromNative ├─────────────
+
+// docFromNative reads a native design. Comments are kept as steps, blank
+// lines are not kept.
+func docFromNative(src string) (*designDoc, error) {
+	src, err := formatDesign(src)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &designDoc{}
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	var header, footer []string
+	i := 0
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "begin-design:"); i++ {
+		header = append(header, lines[i])
+	}
+	if i == len(lines) {
+		return nil, fmt.Errorf("no begin-design: line")
+	}
+
+	// the steps of the open dir blocks, innermost last
+	stack := []*[]docStep{&doc.Steps}
+	for i++; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		steps := stack[len(stack)-1]
+		switch {
+		case l == "":
+			continue
+		case strings.HasPrefix(lines[i], "end-design:"):
+			footer = lines[i+1:]
+			i = len(lines)
+			continue
+		case l == ")":
+			stack = stack[:len(stack)-1]
+			continue
+		case strings.HasPrefix(l, "#"):
+			*steps = append(*steps, docStep{Keyword: "comment", Text: strings.TrimSpace(l[1:])})
+			continue
+		}
+
+		m := lineKeyword.FindStringSubmatch(l)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: %q is not a directive", i+1, l)
+		}
+		st := docStep{Keyword: m[1], Text: strings.TrimSpace(l[len(m[0]):])}
+		if strings.HasSuffix(st.Text, "(") {
+			st.Block = true
+			st.Text = strings.TrimSpace(strings.TrimSuffix(st.Text, "("))
+		}
+		if !st.Block || hasSteps(st.Keyword) {
+			*steps = append(*steps, st)
+			if st.Block {
+				stack = append(stack, &(*steps)[len(*steps)-1].Steps)
+			}
+			continue
+		}
+		if st.Text != "" {
+			return nil, fmt.Errorf("line %d: %s: has text before its block", i+1, st.Keyword)
+		}
+
+		// a block of lines ends at the parenthesis level with its directive
+		ind := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " "))]
+		st.Lines = []string{}
+		for i++; i < len(lines) && lines[i] != ind+")"; i++ {
+			st.Lines = append(st.Lines, strings.TrimPrefix(lines[i], ind+FmtIndent))
+		}
+		*steps = append(*steps, st)
+	}
+
+	doc.Header = strings.TrimSpace(strings.Join(header, "\n"))
+	doc.Footer = strings.TrimSpace(strings.Join(footer, "\n"))
+	return doc, nil
+}
+
+//─────────────┤ native ├─────────────
+
+// native writes the design in the native syntax
+func (doc *designDoc) native() string {
+	var b strings.Builder
+	if doc.Header != "" {
+		b.WriteString(doc.Header + "\n")
+	}
+	b.WriteString("begin-design:\n")
+	// the parser passes over the line that follows begin-design:
+	if len(doc.Steps) == 0 || doc.Steps[0].Keyword != "comment" {
+		b.WriteString("\n")
+	}
+	writeNativeSteps(&b, doc.Steps, "")
+	b.WriteString("end-design:\n")
+	if doc.Footer != "" {
+		b.WriteString(doc.Footer + "\n")
+	}
+	return b.String()
+}
+
+func writeNativeSteps(b *strings.Builder, steps []docStep, ind string) {
+	for _, st := range steps {
+		if st.Keyword == "comment" {
+			b.WriteString(strings.TrimRight(ind+"# "+st.Text, " ") + "\n")
+			continue
+		}
+
+		l := ind + st.Keyword + ":"
+		if st.Text != "" {
+			l += " " + st.Text
+		}
+		if !st.Block {
+			b.WriteString(l + "\n")
+			continue
+		}
+		b.WriteString(l + " (\n")
+		if hasSteps(st.Keyword) {
+			writeNativeSteps(b, st.Steps, ind+FmtIndent)
+		}
+		for _, sl := range st.Lines {
+			b.WriteString(ind + FmtIndent + sl + "\n")
+		}
+		b.WriteString(ind + ")\n")
+	}
+}
+
+//─────────────┤ keepComments ├─────────────
+
+// keepComments adds the comments left over at the end of a yaml or toml
+// design to the end of its steps
+func keepComments(v any, comments []string) any {
+	top, ok := v.(map[string]any)
+	if !ok || len(comments) == 0 {
+		return v
+	}
+	if steps, ok := top["steps"].([]any); ok || top["steps"] == nil {
+		top["steps"] = append(steps, commentSteps(comments)...)
+	}
+	return v
+}
+
+// commentSteps are the steps of the comments, one to a comment
+func commentSteps(comments []string) []any {
+	var steps []any
+	for _, c := range comments {
+		steps = append(steps, map[string]any{"comment": c})
+	}
+	return steps
+}
+
+//─────────────┤ docFromValue ├─────────────
+
+// docFromValue reads a design decoded from yaml, toml or json
+func docFromValue(v any) (*designDoc, error) {
+	top, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("a design is a mapping with a steps list")
+	}
+
+	doc := &designDoc{}
+	for _, k := range sortedKeys(top) {
+		var err error
+		switch k {
+		case "header":
+			doc.Header, err = stringValue(top[k], k)
+		case "footer":
+			doc.Footer, err = stringValue(top[k], k)
+		case "steps":
+			doc.Steps, err = stepsFromValue(top[k], k)
+		default:
+			err = fmt.Errorf("unknown key %s, a design has header, steps and footer", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	doc.Header = strings.TrimSpace(doc.Header)
+	doc.Footer = strings.TrimSpace(doc.Footer)
+	return doc, nil
+}
+
+func stepsFromValue(v any, at string) ([]docStep, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a list of steps", at)
+	}
+
+	steps := []docStep{}
+	for i, sv := range list {
+		st, err := stepFromValue(sv, fmt.Sprintf("%s[%d]", at, i))
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, st)
+	}
+	return steps, nil
+}
+
+func stepFromValue(v any, at string) (docStep, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return docStep{}, fmt.Errorf("%s: a step is a mapping of a directive to its text", at)
+	}
+
+	var st docStep
+	for _, k := range sortedKeys(m) {
+		if k == "steps" {
+			continue
+		}
+		if st.Keyword != "" {
+			return st, fmt.Errorf("%s: a step holds one directive, found %s and %s", at, st.Keyword, k)
+		}
+		st.Keyword = k
+	}
+	switch {
+	case st.Keyword == "":
+		return st, fmt.Errorf("%s: no directive in step", at)
+	case st.Keyword == "begin-design" || st.Keyword == "end-design":
+		return st, fmt.Errorf("%s: %s is implied by the steps list", at, st.Keyword)
+	case st.Keyword != "comment" && !isBuiltinKeyword(st.Keyword) && directives[st.Keyword] == nil:
+		return st, fmt.Errorf("%s: unknown directive %s", at, st.Keyword)
+	}
+	at += "." + st.Keyword
+
+	if sub, ok := m["steps"]; ok {
+		if !hasSteps(st.Keyword) {
+			return st, fmt.Errorf("%s: only dir, if and else have steps", at)
+		}
+		var err error
+		st.Block = true
+		if st.Steps, err = stepsFromValue(sub, at+".steps"); err != nil {
+			return st, err
+		}
+	}
+
+	switch val := m[st.Keyword].(type) {
+	case nil:
+	case string:
+		st.Text = strings.TrimSpace(val)
+		if !strings.Contains(st.Text, "\n") {
+			break
+		}
+		if hasSteps(st.Keyword) || st.Keyword == "comment" {
+			return st, fmt.Errorf("%s: text must be a single line", at)
+		}
+		// text over several lines is a block of them, as a list is
+		st.Text, st.Block = "", true
+		st.Lines = strings.Split(strings.TrimRight(strings.TrimLeft(val, "\n"), " \n"), "\n")
+	case []any:
+		if hasSteps(st.Keyword) || st.Keyword == "comment" {
+			return st, fmt.Errorf("%s: must be text", at)
+		}
+		st.Block = true
+		st.Lines = []string{}
+		for i, lv := range val {
+			l, err := stringValue(lv, fmt.Sprintf("%s[%d]", at, i))
+			if err != nil {
+				return st, err
+			}
+			st.Lines = append(st.Lines, l)
+		}
+	default:
+		return st, fmt.Errorf("%s: must be text or a list of lines", at)
+	}
+	// a condition always has a block, even an empty one
+	if st.Keyword == "if" || st.Keyword == "else" {
+		st.Block = true
+	}
+	return st, nil
+}
+
+// hasSteps reports whether the block of kw holds steps rather than lines
+func hasSteps(kw string) bool {
+	return kw == "dir" || kw == "if" || kw == "else"
+}
+
+func stringValue(v any, at string) (string, error) {
+	s, ok := v.(string)
+	if !ok && v != nil {
+		return "", fmt.Errorf("%s: must be text", at)
+	}
+	return s, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//─────────────┤ value ├─────────────
+
+// docMap is a mapping that keeps the order of its keys when encoded
+type docMap []docField
+
+type docField struct {
+	key string
+	val any
+}
+
+func (m docMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range m {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := writeJSON(&b, f.key, ""); err != nil {
+			return nil, err
+		}
+		b.WriteByte(':')
+		if err := writeJSON(&b, f.val, ""); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// writeJSON encodes v leaving <, > and & as they are, they are common in
+// commands
+func writeJSON(b *bytes.Buffer, v any, indent string) error {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if indent == "" {
+		b.Truncate(b.Len() - 1)
+	}
+	return nil
+}
+
+// value is the design as docMaps, lists and strings in the order the
+// structured formats write it
+func (doc *designDoc) value() docMap {
+	var m docMap
+	if doc.Header != "" {
+		m = append(m, docField{"header", doc.Header})
+	}
+	m = append(m, docField{"steps", stepsValue(doc.Steps)})
+	if doc.Footer != "" {
+		m = append(m, docField{"footer", doc.Footer})
+	}
+	return m
+}
+
+func stepsValue(steps []docStep) []any {
+	list := []any{}
+	for _, st := range steps {
+		m := docMap{{st.Keyword, st.Text}}
+		switch {
+		case hasSteps(st.Keyword) && st.Block:
+			m = append(m, docField{"steps", stepsValue(st.Steps)})
+		case st.Block:
+			lines := []any{}
+			for _, l := range st.Lines {
+				lines = append(lines, l)
+			}
+			m[0].val = lines
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+// quoteString quotes s for yaml and toml, both read these escapes
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,199 @@
+package goproject
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDesignFormat(t *testing.T) {
+	tests := []struct {
+		file, override, want string
+	}{
+		{"go-project.design", "", FormatNative},
+		{"app", "", FormatNative},
+		{"go-project.design.yaml", "", FormatYAML},
+		{"app.YML", "", FormatYAML},
+		{"app.toml", "", FormatTOML},
+		{"app.json", "", FormatJSON},
+		{"app.design", FormatYAML, FormatYAML},
+		{"app.yaml", FormatNative, FormatNative},
+	}
+	for _, tt := range tests {
+		got, err := designFormat(tt.file, tt.override)
+		if err != nil || got != tt.want {
+			t.Errorf("designFormat(%q, %q) = %q, %v, want %q", tt.file, tt.override, got, err, tt.want)
+		}
+	}
+
+	if _, err := designFormat("app.design", "xml"); err == nil {
+		t.Error("designFormat with an unknown format did not fail")
+	}
+}
+
+// designs that every format must carry without changing their steps
+var roundTripDesigns = map[string]string{
+	"steps": `notes before
+begin-design:
+# a comment
+project: app
+dir: app (
+    module: example.com/app
+    exec: echo "# app" > README.md
+    dir: cmd (
+        main: cli
+    )
+    package:
+    test: parse_args
+)
+end-design:
+notes after
+`,
+	"blocks": `begin-design:
+
+project: app
+exec: (
+    if true; then
+        echo 'quoted' "and \ escaped"
+    fi
+)
+git-init: (
+    branch main
+    attributes
+)
+workspace: (
+    ./a
+    ./b
+)
+end-design:
+`,
+	"conditions": `begin-design:
+
+project: app
+if: ${os} == linux (
+    exec: echo linux
+) else: (
+    exec: echo other
+)
+if: exists(go.mod) (
+)
+end-design:
+`,
+	"odd text": `begin-design:
+
+project: app
+exec: echo a: b # not a comment, 'quoted' [x] {y} - z
+exec: echo true
+exec: echo 1.5
+end-design:
+`,
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	designs := map[string]string{}
+	for name, src := range roundTripDesigns {
+		designs[name] = src
+	}
+	for _, name := range presetNames() {
+		src, err := presetSource(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		designs["preset "+name] = src
+	}
+
+	for name, src := range designs {
+		want, err := convertDesign(src, FormatNative, FormatNative)
+		if err != nil {
+			t.Fatalf("%s: native to native: %v", name, err)
+		}
+		for _, f := range []string{FormatYAML, FormatTOML, FormatJSON} {
+			out, err := convertDesign(src, FormatNative, f)
+			if err != nil {
+				t.Errorf("%s: native to %s: %v", name, f, err)
+				continue
+			}
+			again, err := convertDesign(out, f, f)
+			if err != nil {
+				t.Errorf("%s: %s to %s: %v", name, f, f, err)
+			} else if again != out {
+				t.Errorf("%s: %s is not written again as it was\n%s\nthen\n%s", name, f, out, again)
+			}
+			back, err := convertDesign(out, f, FormatNative)
+			if err != nil {
+				t.Errorf("%s: %s to native: %v", name, f, err)
+			} else if back != want {
+				t.Errorf("%s: native to %s and back\n%s\nwant\n%s", name, f, back, want)
+			}
+		}
+	}
+}
+
+func TestConvertKeepsComments(t *testing.T) {
+	tests := []struct {
+		format, src string
+	}{
+		{FormatYAML, "# top\nsteps:\n  - project: app # the name\n  - dir: x\n    steps:\n      # in x\n      - exec: echo\n# end\n"},
+		{FormatTOML, "# top\n[[steps]]\nproject = \"app\" # the name\n[[steps]]\ndir = \"x\"\n# in x\n[[steps.steps]]\nexec = \"echo\"\n# end\n"},
+	}
+	want := "begin-design:\n# top\nproject: app\n# the name\ndir: x (\n    # in x\n    exec: echo\n)\n# end\nend-design:\n"
+
+	for _, tt := range tests {
+		got, err := convertDesign(tt.src, tt.format, FormatNative)
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s to native\n%s\nwant\n%s", tt.format, got, want)
+		}
+	}
+}
+
+func TestDecodeBlockText(t *testing.T) {
+	tests := []struct {
+		format, src string
+	}{
+		{FormatYAML, "steps:\n  - exec: |\n      go mod tidy\n        go build\n"},
+		{FormatYAML, "steps:\n  - exec:\n      - go mod tidy\n      - \"  go build\"\n"},
+		{FormatTOML, "[[steps]]\nexec = '''\ngo mod tidy\n  go build\n'''\n"},
+		{FormatJSON, `{"steps": [{"exec": "go mod tidy\n  go build"}]}`},
+	}
+	want := "begin-design:\n\nexec: (\n    go mod tidy\n      go build\n)\nend-design:\n"
+
+	for _, tt := range tests {
+		doc, err := decodeDesign(tt.src, tt.format)
+		if err != nil {
+			t.Errorf("decodeDesign(%q): %v", tt.src, err)
+			continue
+		}
+		if got := doc.native(); got != want {
+			t.Errorf("decodeDesign(%q)\n%s\nwant\n%s", tt.src, got, want)
+		}
+	}
+}
+
+func TestDecodeDesignErrors(t *testing.T) {
+	tests := []struct {
+		name, format, src, want string
+	}{
+		{"not a mapping", FormatJSON, `[]`, "a design is a mapping with a steps list"},
+		{"unknown key", FormatYAML, "stages: []\n", "unknown key stages"},
+		{"steps not a list", FormatYAML, "steps: x\n", "steps: must be a list of steps"},
+		{"unknown keyword", FormatYAML, "steps:\n  - deploy: x\n", "steps[0]"},
+		{"two keywords", FormatYAML, "steps:\n  - exec: a\n    copy: b\n", "steps[0]"},
+		{"multi-line dir", FormatJSON, `{"steps": [{"dir": "a\nb"}]}`, "steps[0].dir: text must be a single line"},
+		{"multi-line comment", FormatJSON, `{"steps": [{"comment": "a\nb"}]}`, "steps[0].comment: text must be a single line"},
+		{"bad yaml", FormatYAML, "steps:\n\t- exec: a\n", "invalid yaml design: line 2"},
+		{"bad toml", FormatTOML, "steps = [\n", "invalid toml design"},
+		{"bad json", FormatJSON, `{"steps": [}`, "invalid json design"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeDesign(tt.src, tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeDesign(%q) error = %v, want %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package goproject
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is the filesystem a design is executed against. Every file the
+// directives read or write goes through it, commands run by exec:, get:,
+// module: and git-init: are the exception.
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Chmod(name string, mode fs.FileMode) error
+}
+
+//─────────────┤ OSFS ├─────────────
+
+// OSFS is the real filesystem
+type OSFS struct{}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (OSFS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+func (OSFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+//─────────────┤ MemFS ├─────────────
+
+// MemFS is a filesystem held in memory. It starts out empty apart from the
+// root, directories have to be made before files are written into them
+// just as on disk.
+type MemFS struct {
+	files map[string]*memFile
+}
+
+type memFile struct {
+	name string
+	data []byte
+	mode fs.FileMode
+	mod  time.Time
+}
+
+// NewMemFS returns an empty MemFS
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string]*memFile{}}
+}
+
+func (m *MemFS) lookup(name string) (*memFile, bool) {
+	name = filepath.Clean(name)
+	if filepath.Dir(name) == name || name == "." {
+		return &memFile{name: name, mode: fs.ModeDir | 0777}, true
+	}
+	f, ok := m.files[name]
+	return f, ok
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	f, ok := m.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{f}, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	f, ok := m.lookup(name)
+	switch {
+	case !ok:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case f.mode.IsDir():
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return append([]byte{}, f.data...), nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir, ok := m.lookup(name)
+	if !ok || !dir.mode.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	var entries []fs.DirEntry
+	for p, f := range m.files {
+		if filepath.Dir(p) == dir.name && p != dir.name {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{f}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name = filepath.Clean(name)
+	if dir, ok := m.lookup(filepath.Dir(name)); !ok || !dir.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if f, ok := m.files[name]; ok {
+		if f.mode.IsDir() {
+			return &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+		}
+		perm = f.mode
+	}
+	m.files[name] = &memFile{name: name, data: append([]byte{}, data...), mode: perm.Perm(), mod: time.Now()}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	name = filepath.Clean(name)
+	if f, ok := m.lookup(name); ok {
+		if !f.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+	if err := m.MkdirAll(filepath.Dir(name), perm); err != nil {
+		return err
+	}
+	m.files[name] = &memFile{name: name, mode: fs.ModeDir | perm.Perm(), mod: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	f, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	f.mode = f.mode&fs.ModeType | mode.Perm()
+	return nil
+}
+
+// Paths lists everything in the filesystem, directories end with a slash
+func (m *MemFS) Paths() []string {
+	var paths []string
+	for p, f := range m.files {
+		if f.mode.IsDir() {
+			p += string(filepath.Separator)
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+type memInfo struct {
+	f *memFile
+}
+
+func (i memInfo) Name() string       { return filepath.Base(i.f.name) }
+func (i memInfo) Size() int64        { return int64(len(i.f.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.f.mode }
+func (i memInfo) ModTime() time.Time { return i.f.mod }
+func (i memInfo) IsDir() bool        { return i.f.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+//─────────────┤ OverlayFS ├─────────────
+
+// OverlayFS reads through to a lower filesystem and keeps every change in
+// an upper one, the lower filesystem is never written to. Over the real
+// disk it lets a design see existing files while creating nothing.
+type OverlayFS struct {
+	Lower FS
+	Upper FS
+}
+
+// NewOverlayFS returns an OverlayFS over lower whose changes are held in
+// memory
+func NewOverlayFS(lower FS) *OverlayFS {
+	return &OverlayFS{Lower: lower, Upper: NewMemFS()}
+}
+
+func (o *OverlayFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := o.Upper.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.Lower.Stat(name)
+	}
+	return info, err
+}
+
+func (o *OverlayFS) ReadFile(name string) ([]byte, error) {
+	b, err := o.Upper.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.Lower.ReadFile(name)
+	}
+	return b, err
+}
+
+// ReadDir merges the entries of both layers, those of the upper one win
+func (o *OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, uerr := o.Upper.ReadDir(name)
+	lower, lerr := o.Lower.ReadDir(name)
+	if uerr != nil && lerr != nil {
+		return nil, lerr
+	}
+
+	seen := map[string]bool{}
+	entries := append([]fs.DirEntry{}, upper...)
+	for _, e := range upper {
+		seen[e.Name()] = true
+	}
+	for _, e := range lower {
+		if !seen[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (o *OverlayFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if err := o.copyUpDir(filepath.Dir(name)); err != nil {
+		return err
+	}
+	return o.Upper.WriteFile(name, data, perm)
+}
+
+func (o *OverlayFS) MkdirAll(name string, perm fs.FileMode) error {
+	if info, err := o.Lower.Stat(name); err == nil && !info.IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return o.Upper.MkdirAll(name, perm)
+}
+
+func (o *OverlayFS) Chmod(name string, mode fs.FileMode) error {
+	if _, err := o.Upper.Stat(name); errors.Is(err, fs.ErrNotExist) {
+		b, err := o.Lower.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := o.WriteFile(name, b, mode); err != nil {
+			return err
+		}
+	}
+	return o.Upper.Chmod(name, mode)
+}
+
+// copyUpDir makes dir in the upper layer when it only exists in the lower
+func (o *OverlayFS) copyUpDir(dir string) error {
+	if _, err := o.Upper.Stat(dir); err == nil {
+		return nil
+	}
+	info, err := o.Lower.Stat(dir)
+	if err != nil {
+		return err
+	}
+	return o.Upper.MkdirAll(dir, info.Mode().Perm())
+}
+
+//─────────────┤ copyFile ├─────────────
+
+// copyFile copies src to dst within fsys, making the directory of dst
+// when it is missing
+func copyFile(fsys FS, dst, src string) error {
+	src, err := expandHome(src)
+	if err != nil {
+		return err
+	}
+	dst, err = expandHome(dst)
+	if err != nil {
+		return err
+	}
+
+	b, err := fsys.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return err
+	}
+	return fsys.WriteFile(dst, b, 0666)
+}
+
+// expandHome resolves a leading ~ and makes name absolute
+func expandHome(name string) (string, error) {
+	if name == "~" || strings.HasPrefix(name, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		name = filepath.Join(home, name[1:])
+	}
+	return filepath.Abs(name)
+}
+
+//─────────────┤ findFiles ├─────────────
+
+// findFiles lists the files below dir in fsys, like find -type f
+func findFiles(fsys FS, dir string) ([]string, error) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if !e.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		sub, err := findFiles(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sub...)
+	}
+	return files, nil
+}
+
+// isDir reports whether name is a directory in fsys
+func isDir(fsys FS, name string) bool {
+	info, err := fsys.Stat(name)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,145 @@
+package goproject
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	if err := m.MkdirAll("/a/b/c", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("/a/b/f.txt", []byte("f"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		op       func() error
+		notFound bool
+		fails    bool
+	}{
+		{"stat root", func() error { _, err := m.Stat("/"); return err }, false, false},
+		{"stat parent made by MkdirAll", func() error { return wantDir(m, "/a") }, false, false},
+		{"stat made dir", func() error { return wantDir(m, "/a/b/c") }, false, false},
+		{"stat missing", func() error { _, err := m.Stat("/a/x"); return err }, true, true},
+		{"stat below missing parent", func() error { _, err := m.Stat("/x/y/z"); return err }, true, true},
+		{"write with missing parent", func() error { return m.WriteFile("/x/f.txt", nil, 0644) }, true, true},
+		{"write over dir", func() error { return m.WriteFile("/a/b", nil, 0644) }, false, true},
+		{"mkdir over file", func() error { return m.MkdirAll("/a/b/f.txt/d", 0755) }, false, true},
+		{"mkdir existing", func() error { return m.MkdirAll("/a/b", 0755) }, false, false},
+		{"read dir as file", func() error { _, err := m.ReadFile("/a"); return err }, false, true},
+		{"read missing", func() error { _, err := m.ReadFile("/a/x"); return err }, true, true},
+		{"read dir missing", func() error { _, err := m.ReadDir("/x"); return err }, true, true},
+		{"chmod missing", func() error { return m.Chmod("/x", 0600) }, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.op()
+			if (err != nil) != tt.fails {
+				t.Fatalf("error = %v, want failure %t", err, tt.fails)
+			}
+			if errors.Is(err, fs.ErrNotExist) != tt.notFound {
+				t.Errorf("error = %v, want not found %t", err, tt.notFound)
+			}
+		})
+	}
+
+	want := []string{"/a/", "/a/b/", "/a/b/c/", "/a/b/f.txt"}
+	if got := m.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Paths() = %q, want %q", got, want)
+	}
+	var names []string
+	entries, err := m.ReadDir("/a/b")
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if err != nil || !reflect.DeepEqual(names, []string{"c", "f.txt"}) {
+		t.Errorf("ReadDir(/a/b) = %q, %v", names, err)
+	}
+}
+
+// wantDir fails unless name is a directory of fsys
+func wantDir(fsys FS, name string) error {
+	info, err := fsys.Stat(name)
+	if err == nil && !info.IsDir() {
+		err = errors.New(name + " is not a directory")
+	}
+	return err
+}
+
+func TestOverlayFS(t *testing.T) {
+	lower := NewMemFS()
+	for _, d := range []string{"/p/src", "/p/docs"} {
+		if err := lower.MkdirAll(d, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, text := range map[string]string{"/p/go.mod": "lower", "/p/src/a.go": "a", "/p/run.sh": "run"} {
+		if err := lower.WriteFile(name, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := lower.Paths()
+
+	o := NewOverlayFS(lower)
+	tests := []struct {
+		name  string
+		op    func() error
+		fails bool
+	}{
+		{"write into a lower dir", func() error { return o.WriteFile("/p/src/b.go", []byte("b"), 0644) }, false},
+		{"write over a lower file", func() error { return o.WriteFile("/p/go.mod", []byte("upper"), 0644) }, false},
+		{"chmod a lower file", func() error { return o.Chmod("/p/run.sh", 0755) }, false},
+		{"make a new dir", func() error { return o.MkdirAll("/p/docs/api/v1", 0755) }, false},
+		{"write into a new dir", func() error { return o.WriteFile("/p/docs/api/v1/x.md", []byte("x"), 0644) }, false},
+		{"write with a missing parent", func() error { return o.WriteFile("/q/x", nil, 0644) }, true},
+		{"mkdir over a lower file", func() error { return o.MkdirAll("/p/go.mod/x", 0755) }, true},
+		{"chmod a missing file", func() error { return o.Chmod("/p/none", 0755) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); (err != nil) != tt.fails {
+				t.Errorf("error = %v, want failure %t", err, tt.fails)
+			}
+		})
+	}
+
+	if got := lower.Paths(); !reflect.DeepEqual(got, paths) {
+		t.Errorf("the lower layer was written to, %q became %q", paths, got)
+	}
+	for name, want := range map[string]string{"/p/go.mod": "lower", "/p/src/a.go": "a"} {
+		if b, err := lower.ReadFile(name); err != nil || string(b) != want {
+			t.Errorf("lower %s = %q, %v, want %q", name, b, err, want)
+		}
+	}
+	if info, _ := lower.Stat("/p/run.sh"); info.Mode().Perm() != 0644 {
+		t.Errorf("lower /p/run.sh mode = %v, want it unchanged", info.Mode())
+	}
+
+	for name, want := range map[string]string{"/p/go.mod": "upper", "/p/src/a.go": "a", "/p/src/b.go": "b", "/p/run.sh": "run"} {
+		if b, err := o.ReadFile(name); err != nil || string(b) != want {
+			t.Errorf("overlay %s = %q, %v, want %q", name, b, err, want)
+		}
+	}
+	if info, err := o.Stat("/p/run.sh"); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("overlay /p/run.sh = %v, %v, want mode 0755", info, err)
+	}
+	// the lower directory is copied up with its mode
+	if info, err := o.Upper.Stat("/p/src"); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("upper /p/src = %v, %v, want a copy of the lower dir", info, err)
+	}
+
+	var names []string
+	entries, err := o.ReadDir("/p/src")
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if err != nil || !reflect.DeepEqual(names, []string{"a.go", "b.go"}) {
+		t.Errorf("ReadDir(/p/src) = %q, %v, want both layers", names, err)
+	}
+}
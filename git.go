@@ -0,0 +1,200 @@
+package goproject
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"bitbucket.org/creachadair/shell"
+)
+
+const DefaultCommitMessage = "Initial commit"
+
+// gitInitParams holds the options of a git-init: directive. A bare
+// git-init: leaves everything at its zero value and only runs git init.
+type gitInitParams struct {
+	branch     string
+	commit     bool
+	message    string
+	userName   string
+	userEmail  string
+	remoteName string
+	remoteURL  string
+	attributes []string
+	hooks      []gitHook
+}
+
+type gitHook struct {
+	name, src string
+}
+
+//─────────────┤ defaults ├─────────────
+
+// defaults fills in the branch and user the design leaves out from the
+// git settings of the config file
+func (gp *gitInitParams) defaults(cfg config) {
+	if gp.branch == "" {
+		gp.branch = cfg["git.branch"]
+	}
+	if gp.userName == "" {
+		gp.userName = cfg["git.user.name"]
+	}
+	if gp.userEmail == "" {
+		gp.userEmail = cfg["git.user.email"]
+	}
+}
+
+//─────────────┤ parseGitInitParams ├─────────────
+
+// parseGitInitParams accepts the text following the git-init: keyword with
+// any parentheses already stripped. Each line holds one option:
+//
+//	branch <name>
+//	commit [message]
+//	user.name <name>
+//	user.email <email>
+//	remote [name] <url>
+//	attributes [pattern attr...]
+//	hook <hook-name> <script>
+func parseGitInitParams(text string) (gitInitParams, error) {
+	var gp gitInitParams
+
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.Trim(l, "\t ")
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		rest := strings.Trim(strings.TrimPrefix(l, fields[0]), "\t ")
+		switch fields[0] {
+		case "branch":
+			if len(fields) != 2 {
+				return gp, fmt.Errorf("git-init: branch requires a single name")
+			}
+			gp.branch = fields[1]
+		case "commit":
+			gp.commit = true
+			gp.message = strings.Trim(rest, `"`)
+			if gp.message == "" {
+				gp.message = DefaultCommitMessage
+			}
+		case "user.name":
+			gp.userName = strings.Trim(rest, `"`)
+		case "user.email":
+			gp.userEmail = strings.Trim(rest, `"`)
+		case "remote":
+			switch len(fields) {
+			case 2:
+				gp.remoteName, gp.remoteURL = "origin", fields[1]
+			case 3:
+				gp.remoteName, gp.remoteURL = fields[1], fields[2]
+			default:
+				return gp, fmt.Errorf("git-init: remote requires [name] <url>")
+			}
+		case "attributes":
+			if rest == "" {
+				rest = "* text=auto"
+			}
+			gp.attributes = append(gp.attributes, rest)
+		case "hook":
+			if len(fields) != 3 {
+				return gp, fmt.Errorf("git-init: hook requires <hook-name> <script>")
+			}
+			gp.hooks = append(gp.hooks, gitHook{name: fields[1], src: fields[2]})
+		default:
+			return gp, fmt.Errorf("git-init: unknown option %s", fields[0])
+		}
+	}
+
+	return gp, nil
+}
+
+//─────────────┤ initGitRepo ├─────────────
+
+func initGitRepo(ctx context.Context, p *designParser, dir string, gp gitInitParams) error {
+	args := []string{"git", "init"}
+	if gp.branch != "" {
+		args = append(args, "--initial-branch="+gp.branch)
+	}
+
+	cmds := [][]string{args}
+	if gp.userName != "" {
+		cmds = append(cmds, []string{"git", "config", "user.name", gp.userName})
+	}
+	if gp.userEmail != "" {
+		cmds = append(cmds, []string{"git", "config", "user.email", gp.userEmail})
+	}
+	if gp.remoteURL != "" {
+		cmds = append(cmds, []string{"git", "remote", "add", gp.remoteName, gp.remoteURL})
+	}
+
+	for _, c := range cmds {
+		p.infof("%s  [in %s]", shell.Join(c), dir)
+		_, err := execIn(ctx, p.runner, dir, shell.Join(c))
+		if err != nil {
+			p.setError(fmt.Errorf("error initializing git repo in %s: %v", dir, err))
+			return err
+		}
+	}
+
+	if len(gp.attributes) > 0 {
+		attr := filepath.Join(dir, ".gitattributes")
+		p.infof("write %s", attr)
+		err := p.fs.WriteFile(attr, []byte(strings.Join(gp.attributes, "\n")+"\n"), 0666)
+		if err != nil {
+			p.setError(fmt.Errorf("error writing %s", attr))
+			return err
+		}
+	}
+
+	for _, h := range gp.hooks {
+		hook := filepath.Join(dir, ".git", "hooks", h.name)
+		p.infof("copy %s -> %s", h.src, hook)
+		err := copyFile(p.fs, hook, h.src)
+		if err == nil {
+			err = p.fs.Chmod(hook, 0755)
+		}
+		if err != nil {
+			p.setError(fmt.Errorf("error installing git hook %s from %s", h.name, h.src))
+			return err
+		}
+	}
+
+	return nil
+}
+
+//─────────────┤ commitGitRepo ├─────────────
+
+// commitGitRepo makes the initial commit of everything generated in dir.
+// It is called once the whole design has been executed.
+func commitGitRepo(ctx context.Context, p *designParser, dir string, gp gitInitParams) error {
+	for _, c := range [][]string{
+		{"git", "add", "-A"},
+		{"git", "commit", "-q", "-m", gp.message},
+	} {
+		p.infof("%s  [in %s]", shell.Join(c), dir)
+		_, err := execIn(ctx, p.runner, dir, shell.Join(c))
+		if err != nil {
+			p.setError(fmt.Errorf("error making initial commit in %s: %v", dir, err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+//─────────────┤ execIn ├─────────────
+
+// execIn runs command with dir as the working directory and returns its
+// combined output. The output is folded into the error on failure.
+func execIn(ctx context.Context, r Runner, dir, command string) (string, error) {
+	var out strings.Builder
+	err := execContext(ctx, r, dir, command, &out)
+	if err != nil {
+		return out.String(), fmt.Errorf("%s: %v\n%s", command, err, strings.TrimRight(out.String(), "\n"))
+	}
+
+	return out.String(), nil
+}
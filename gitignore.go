@@ -0,0 +1,131 @@
+package goproject
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is one line of a .gitignore file. base is the slash separated
+// directory holding the .gitignore, relative to the root being walked.
+type ignoreRule struct {
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// gitIgnore holds the rules of every .gitignore read so far, in the order
+// git applies them, the last matching rule decides.
+type gitIgnore struct {
+	rules []ignoreRule
+}
+
+//─────────────┤ load ├─────────────
+
+// load reads the .gitignore in dir, if there is one. rel is dir relative
+// to the root of the walk.
+func (g *gitIgnore) load(dir, rel string) {
+	b, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimRight(l, "\r\t ")
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		r := ignoreRule{base: filepath.ToSlash(rel)}
+		if strings.HasPrefix(l, "!") {
+			r.negate = true
+			l = l[1:]
+		}
+		if strings.HasSuffix(l, "/") {
+			r.dirOnly = true
+			l = strings.TrimSuffix(l, "/")
+		}
+		// a slash anywhere but the end ties the pattern to the .gitignore directory
+		if strings.Contains(l, "/") {
+			r.anchored = true
+			l = strings.TrimPrefix(l, "/")
+		}
+		r.pattern = l
+		g.rules = append(g.rules, r)
+	}
+}
+
+//─────────────┤ ignored ├─────────────
+
+// ignored reports whether the slash separated path rel, relative to the
+// root of the walk, is excluded.
+func (g *gitIgnore) ignored(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	ign := false
+
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		sub := rel
+		if r.base != "." && r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = strings.TrimPrefix(rel, r.base+"/")
+		}
+
+		var m bool
+		if r.anchored {
+			m = globMatch(r.pattern, sub)
+		} else {
+			m, _ = path.Match(r.pattern, path.Base(sub))
+		}
+		if m {
+			ign = !r.negate
+		}
+	}
+
+	return ign
+}
+
+//─────────────┤ globMatch ├─────────────
+
+// globMatch is path.Match extended with ** matching any number of
+// directories.
+func globMatch(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		m, _ := path.Match(pattern, name)
+		return m
+	}
+
+	pp := strings.Split(pattern, "/")
+	np := strings.Split(name, "/")
+	var match func(i, j int) bool
+	match = func(i, j int) bool {
+		for i < len(pp) {
+			if pp[i] == "**" {
+				for k := j; k <= len(np); k++ {
+					if match(i+1, k) {
+						return true
+					}
+				}
+				return false
+			}
+			if j >= len(np) {
+				return false
+			}
+			if m, _ := path.Match(pp[i], np[j]); !m {
+				return false
+			}
+			i++
+			j++
+		}
+		return j == len(np)
+	}
+	return match(0, 0)
+}
@@ -3,13 +3,13 @@ module github.com/westarver/go-project
 go 1.18
 
 require (
+	bitbucket.org/creachadair/shell v0.0.7
 	github.com/bitfield/script v0.20.2
 	github.com/westarver/boa v0.0.0-20220804202030-a60353d0a88a
 	github.com/westarver/messenger v0.0.0-20220701000639-879643136c65
 )
 
 require (
-	bitbucket.org/creachadair/shell v0.0.7 // indirect
 	github.com/itchyny/gojq v0.12.7 // indirect
 	github.com/itchyny/timefmt-go v0.1.3 // indirect
 	github.com/rhysd/abspath v0.0.0-20200817132137-9532ba017882
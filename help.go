@@ -3,82 +3,607 @@ package goproject
 import (
 	"fmt"
 	"io"
-	"os"
+	"strings"
 )
 
+//go:generate sh -c "go run ./cmd/go-project help markdown > README.md"
+
+// helpItem is a command or flag. usage is the form boa parses, the name
+// in brackets followed by its argument.
+type helpItem struct {
+	usage string
+	short string
+	long  string
+}
+
+// directiveHelp is the reference page of one design keyword
+type directiveHelp struct {
+	name    string
+	syntax  []string
+	short   string
+	long    string
+	nesting string
+	example string
+}
+
+// the one source of help, getUsage, ShowHelp and README.md are made from these
+var commandHelp = []helpItem{
+	{"*+[help] <topic>...", "Show help on a command, flag or topic and exit, default command.",
+		`With no topic prints this summary. A topic is a command (help init), a flag
+(help --design), design for the design file format, directives for a list of
+the design keywords, directive <name> for the reference page of one of them,
+or markdown for all of the help as markdown, which is how README.md is made.`},
+	{"*[init] <name>", "Create a project root directory with optional sub directories.",
+		`The init command with name will create a minimal project with only a root
+directory and a readme file. More functionality can be had by using a design
+file, see help design. To use init without following with a name use -- for
+name. If no name is given, (eg. --) then a name must appear in the design file.`},
+	{"*[presets] <preset>", "List the built-in presets, or print the design of the one named.",
+		`With no preset named, lists the presets built into go-project. With a preset
+named, prints its design so it can be saved and used as the starting point of
+a custom design.`},
+	{"*[designs] <action>...", "Manage your design library with list, show, add and remove.",
+		`Manage the design library kept in $XDG_CONFIG_HOME/go-project/designs. list
+shows the names, show <name> prints a design, add <file> [name] copies a design
+file into the library and remove <name> deletes one. A yaml, toml or json
+design is kept in its own format. Library designs are used with
+--design <name>.`},
+	{"*[validate]", "Check a design for errors without executing it.",
+		`Parse the design given by --design or --preset and check it without executing
+anything. Reports unknown keywords, duplicate directories, workspace modules
+that are never created, missing copy sources and invalid module paths. Exits
+with 0 when the design is clean, 3 when it has errors and 4 when it has only
+warnings.`},
+	{"*[plan] <name>", "Show what init <name> would do, as a tree or as json.",
+		`Parse the design and print every step init would carry out without executing
+any of them. Each step shows its line in the design, the command and its
+parameters, indented by nesting depth, and the directory it runs in relative
+to the current one. Use --format json for a form that can be diffed and
+processed by other tools.`},
+	{"*[new-design] <file>", "Answer a few questions and write a new design file.",
+		`Ask for the project name, module path, license, directory layout and whether
+to initialize git and a workspace, then write a design to <file>,
+go-project.design when -- is given. The flags give the default answers and
+with --yes no questions are asked at all, eg.
+go-project new-design -- --name app --layout cmd,internal --git --yes`},
+	{"*[capture] <dir>", "Write a design that recreates an existing directory tree.",
+		`Walk <dir> and print a design that recreates it. Directories become nested
+dir: blocks, go.mod becomes module:, go.work becomes workspace: and a .git
+directory becomes git-init: with its branch and origin. Text files up to
+--inline-size bytes are written into the design as an exec: of printf, other
+files up to --max-size bytes become copy: entries reading from <dir> and
+larger ones are noted in a comment. copy: paths are absolute unless --source
+names a directory, they are then relative to ${source} and init is given the
+directory with --var source=<dir>. Paths in .gitignore are left out, eg.
+go-project capture ~/src/app --name app > app.design`},
+	{"*[diff] <dir>", "Compare a design with the existing project in <dir>.",
+		`Compare what the design would create with the project in <dir>, as if init
+ran in the parent of <dir> with the last element of <dir> as the project name
+(--name gives another). Every directory, file and module is reported as
+create, exists or differs, with a unified diff for files that differ. exec:
+and get: are reported as skipped. Exits with 5 when anything would be created
+or differs, eg. go-project diff services/billing --design standard`},
+	{"*[fmt] <file>...", "Print design files in canonical form, or rewrite them with --write.",
+		`Reformat design files, the one given by --design when no file is named. Lines
+are indented four spaces per nesting level, blocks open at the end of their
+directive and close on a line of their own, blank lines are collapsed and
+comments are kept. The lines of a block of text, such as an exec: script,
+keep their indentation relative to the first of them. A design that does not
+parse, or whose meaning would change, is left alone. yaml, toml and json
+designs are written again the way convert writes them, their own # comments
+become comment: steps. The output of fmt is unchanged by fmt.
+--diff exits with 5 when any file is not formatted, it has no short form as
+-d is --design, eg. go-project fmt -w *.design`},
+	{"*[config] <action>...", "Show or change your settings with list, get <key>, set <key> [value] and path.",
+		`Manage the settings kept in $XDG_CONFIG_HOME/go-project/config.toml. list shows
+every key, get <key> prints one, set <key> <value> changes one and set <key>
+with no value removes it, path prints the name of the file. The keys are
+author, holder, license, module.prefix, git.branch, git.user.name and
+git.user.email. Each is a variable in designs, ${author} or ${module.prefix},
+and they are the defaults of module:, license: and git-init:, eg.
+go-project config set module.prefix github.com/ourorg/`},
+	{"*[convert] <file>", "Translate a design between the native, yaml, toml and json formats.",
+		`Print the design <file> in the format given by --to, native, yaml, toml or
+json. The format of <file> is told by its extension, or by --design-format.
+Comments and the text around the design are kept, blank lines are not. The
+file is not passed through xpanda so macros survive, and a design whose
+steps would change is not converted, eg.
+go-project convert go-project.design --to yaml > go-project.design.yaml`},
+	{"*[schema]", "Print the JSON Schema of yaml, toml and json designs.",
+		`Print a JSON Schema of the yaml, toml and json design formats, made from the
+directives this build of go-project knows, registered ones included. Editors
+use it to check and complete designs, eg. save it with
+go-project schema > go-project.schema.json and start a yaml design with
+# yaml-language-server: $schema=go-project.schema.json`},
+	{"*[completion] <shell>", "Print the completion script for bash, zsh or fish.",
+		`Print a script completing commands, flags, design files, preset and library
+design names and help topics. Load it from the shell startup file, eg.
+source <(go-project completion bash) in ~/.bashrc,
+source <(go-project completion zsh) in ~/.zshrc or
+go-project completion fish > ~/.config/fish/completions/go-project.fish`},
+}
+
+var flagHelp = []helpItem{
+	{"[--design | -d] <design>", "File where project details are given.",
+		`The design file describes the project, see help design. The --design flag is
+optional, if not given the app will look for a file named 'go-project.design'
+in the current directory. If that is not found then the program will exit with
+an exit code of 2. A design that is not a file is looked up by name in your
+design library.`},
+	{"[--preset | -p] <preset>", "Use a built-in design (cli, library, service, monorepo) with init.",
+		`Use one of the built-in designs instead of a design file. A project name must
+be given to init, it replaces ${project} in the preset, eg.
+go-project init myapp --preset cli`},
+	{"[--format | -f] <format>", "Output format of validate, plan or diff, text (tree for plan) or json.",
+		`Output format for validate, plan and diff. text (tree for plan) is meant for
+reading and json is a single object for use by other tools.`},
+	{"[--design-format] <format>", "Read the design as native, yaml, toml or json whatever its extension.", ""},
+	{"[--to] <format>", "Format convert writes, native, yaml, toml or json.", ""},
+	{"[--var] <vars>", "Set design variables for if: conditions, eg. service=true,db=postgres.", ""},
+	{"[--name] <name>", "Project name for new-design, capture or diff.", ""},
+	{"[--module] <module>", "Module path for new-design.", ""},
+	{"[--license] <license>", "License for new-design, MIT, Apache-2.0, BSD-3-Clause or none.", ""},
+	{"[--holder] <holder>", "Copyright holder named in the license for new-design.", ""},
+	{"[--layout] <layout>", "Comma separated directories for new-design, any of cmd, internal, pkg.", ""},
+	{"[--git]", "new-design includes git-init:.", ""},
+	{"[--workspace]", "new-design includes workspace:.", ""},
+	{"[--yes | -y]", "new-design asks no questions, flags give every answer.", ""},
+	{"[--max-size] <bytes>", "Largest file capture turns into a copy:, 65536 by default.", ""},
+	{"[--inline-size] <bytes>", "Largest text file capture writes into the design, 4096 by default, 0 for none.", ""},
+	{"[--source] <dir>", "Directory capture writes copy: paths relative to, as ${source}.", ""},
+	{"[--write | -w]", "fmt rewrites the files instead of printing them.", ""},
+	{"[--diff]", "fmt prints a unified diff of the changes it would make, -d is --design.", ""},
+	{"[--verbose | -v]", "Report every operation of every step init runs.", ""},
+	{"[--debug | -vv]", "Report what the parser does as well as -v.", ""},
+	{"[--quiet | -q]", "Report errors only, the output of commands is left out too.", ""},
+	{"[--log-file] <file>", "Append messages to file instead of writing them to stderr.", ""},
+	{"[--log-format] <format>", "Messages as text, the default, or json, one object per line.",
+		`With json every message is an object on a line of its own with time, level
+(error, notice, info or debug) and msg. Messages about a step of init also
+carry event, step, line, command, path and, once it has finished, status,
+duration_ms and error. What the commands of a step print is a notice of the
+step for each line instead of going to stdout. --log-format=json and
+--log-format json are the same.`},
+}
+
+const designHelp = `A design file describes a project as a list of directives, one keyword per
+line followed by a colon and its parameters. Everything outside of the
+begin-design: and end-design: lines is ignored, so a design can carry notes
+before and after it. Lines starting with # are comments and blank lines are
+ignored.
+
+The file is passed through xpanda before it is read, so macros can be used,
+and ${project} is replaced by the project name, the one given to init or the
+one on the project: line. The settings of go-project config are variables too,
+${author}, ${holder}, ${license}, ${module.prefix}, ${git.branch},
+${git.user.name} and ${git.user.email}.
+
+A dir: directive followed by ( opens a block that lasts until the matching ).
+Every directive in the block runs in that directory, blocks can be nested and
+paths given to dir: are relative to the directory go-project runs in, not to
+the enclosing block. A few directives such as git-init: and workspace: take a
+block of options in the same way.
+
+An if: block is kept only when its condition holds and an else: block after
+it only when it does not, see go-project help directive if.
+
+A design can also be written in yaml, toml or json, in a file ending in .yaml,
+.yml, .toml or .json such as go-project.design.yaml, or any file with
+--design-format. It is a mapping whose steps list holds the directives, each
+step maps one keyword to its text, a block of lines is a list or text over
+several lines, such as a yaml | or > block, and a dir:, if: or else: block has
+steps of its own. Comments are steps too, comment: <text>, and the # comments
+of yaml and toml become such steps where they are. header and footer hold the
+notes before and after the design. The design is read as the native lines it
+stands for, so errors are reported at those lines.
+go-project convert translates between the formats.
+
+    steps:
+      - project: hello
+      - dir: ${project}
+        steps:
+          - module: example.com/${project}
+          - git-init: [branch main]
+
+Run go-project help directives for the list of keywords and
+go-project help directive <name> for each of them. validate, plan and fmt
+check, show and tidy a design without executing it.`
+
+const exitCodesHelp = `go-project exits with one of these codes:
+
+    0  success
+    1  usage error, or a command other than init failed
+    2  the design file or preset was not found or could not be read
+    3  the design has errors, init executes nothing
+    4  validate found warnings but no errors
+    5  diff found drift, or fmt --diff found changes
+    6  init failed at every step it carried out
+    7  init carried out some steps and failed at others
+  130  init was interrupted by Ctrl-C or SIGTERM, running commands are
+       killed and the steps done, interrupted and not run are listed
+
+The last message of a run summarizes it, init reports how many steps were
+done and how many failed.`
+
+const designExample = `begin-design:
+# a command line tool
+project: hello
+
+dir: ${project} (
+    module: example.com/${project}
+    license: MIT
+    git-init:
+)
+dir: ${project}/cmd/${project} (
+    main: cli
+)
+end-design:`
+
+var directivesHelp = []directiveHelp{
+	{
+		name:   "begin-design",
+		syntax: []string{"begin-design:"},
+		short:  "Start of the design, lines before it are ignored.",
+		long: `Marks the start of the design. The first line after begin-design: is not
+read as a directive, start with a comment or a blank line.`,
+		nesting: "Must not be inside a block.",
+	},
+	{
+		name:    "end-design",
+		syntax:  []string{"end-design:"},
+		short:   "End of the design, lines after it are ignored.",
+		long:    "Marks the end of the design. Without it the design runs to the end of the file.",
+		nesting: "Must not be inside a block.",
+	},
+	{
+		name:   "project",
+		syntax: []string{"project: <name>"},
+		short:  "Name of the project, the value of ${project}.",
+		long: `Names the project. A name given on the command line to init, plan or diff
+takes the place of this one.`,
+		nesting: "Anywhere, usually first.",
+		example: "project: hello",
+	},
+	{
+		name:   "dir",
+		syntax: []string{"dir: <path>", "dir: <path> (\n    <directives>\n)"},
+		short:  "Create a directory, optionally with a block of directives run in it.",
+		long: `Creates the directory and any missing parents. The path is relative to the
+directory go-project runs in whatever block it is in.`,
+		nesting: `Opens a block when followed by (, the directives up to the matching ) run
+in the new directory. Blocks can be nested.`,
+		example: "dir: ${project} (\n    dir: ${project}/internal\n)",
+	},
+	{
+		name:    "exec",
+		syntax:  []string{"exec: <command>", "exec: (\n    <command>\n)"},
+		short:   "Run a command.",
+		long:    "Runs the command with its arguments. A command written over several lines is enclosed in parentheses.",
+		nesting: "Runs in the directory of the enclosing block.",
+		example: `exec: echo "# ${project}" > README.md`,
+	},
+	{
+		name:   "copy",
+		syntax: []string{"copy: <file>...", "copy: <dir>"},
+		short:  "Copy files or the contents of a directory.",
+		long: `Copies each file into the directory of the enclosing block. A relative path
+keeps its path below the block directory, an absolute one keeps only its
+name. Names with spaces are quoted. A directory has all of its files copied.`,
+		nesting: "Copies into the directory of the enclosing block.",
+		example: "copy: ~/templates/.gitignore \"notes/read me.txt\"",
+	},
+	{
+		name:    "get",
+		syntax:  []string{"get: <url>"},
+		short:   "Download a file with wget.",
+		nesting: "Downloads into the directory of the enclosing block.",
+		example: "get: https://example.com/logo.png",
+	},
+	{
+		name:   "module",
+		syntax: []string{"module: [module path]"},
+		short:  "Initialize a go module with go mod init.",
+		long: `Runs go mod init with the module path. validate checks the path. Without a
+path the module is named by the module.prefix setting followed by the path of
+the directory, github.com/ourorg/${project} for the project root.`,
+		nesting: "Creates go.mod in the directory of the enclosing block.",
+		example: "module: example.com/${project}",
+	},
+	{
+		name:   "workspace",
+		syntax: []string{"workspace: <dir>...", "workspace: (\n    go <version>\n    use <dir>...\n    replace <module> => <path>\n)"},
+		short:  "Write a go.work file using the listed modules.",
+		long: `Writes go.work with a use entry for each module directory, relative to the
+block directory. The go version defaults to the newest go version of the
+modules it uses. Every module must have a go.mod by then, so workspace:
+follows the module: directives it uses. An existing go.work is never overwritten.`,
+		nesting: "Writes go.work in the directory of the enclosing block.",
+		example: "workspace: (\n    go 1.21\n    ./api ./worker\n)",
+	},
+	{
+		name:   "git-init",
+		syntax: []string{"git-init:", "git-init: (\n    <option>\n)"},
+		short:  "Initialize a git repository.",
+		long: `Runs git init. The branch and user default to the git settings of
+go-project config. The options are
+    branch <name>           initial branch
+    commit [message]        commit everything once the design has run
+    user.name <name>        local user.name
+    user.email <email>      local user.email
+    remote [name] <url>     add a remote, origin by default
+    attributes [line...]    write .gitattributes, * text=auto by default
+    hook <name> <script>    install a hook script`,
+		nesting: "Initializes the directory of the enclosing block.",
+		example: "git-init: (\n    branch main\n    commit\n)",
+	},
+	{
+		name:    "main",
+		syntax:  []string{"main:", "main: cli"},
+		short:   "Write main.go, with cli a command line skeleton.",
+		long:    "Writes a main package in main.go. cli writes a command line skeleton using the standard flag package. An existing file is never overwritten.",
+		nesting: "Writes into the directory of the enclosing block.",
+		example: "main: cli",
+	},
+	{
+		name:    "package",
+		syntax:  []string{"package: [name]"},
+		short:   "Write doc.go for a library package.",
+		long:    "Writes doc.go declaring the package, named after the directory when no name is given.",
+		nesting: "Writes into the directory of the enclosing block.",
+		example: "package: server",
+	},
+	{
+		name:   "test",
+		syntax: []string{"test: [name]"},
+		short:  "Write a skipped test file.",
+		long: `Writes <name>_test.go with a skipped test in the package of the directory.
+The name defaults to the package name.`,
+		nesting: "Writes into the directory of the enclosing block.",
+		example: "test: parse_args",
+	},
+	{
+		name:   "license",
+		syntax: []string{"license: [id] [holder]"},
+		short:  "Write a LICENSE file.",
+		long: `Writes LICENSE for MIT, Apache-2.0 or BSD-3-Clause. The id defaults to the
+license setting and the holder to the holder setting, then the author setting
+and then the project name.`,
+		nesting: "Writes into the directory of the enclosing block.",
+		example: "license: MIT Jane Doe",
+	},
+	{
+		name:   "if",
+		syntax: []string{"if: <expr> (\n    <directives>\n)"},
+		short:  "Run a block of directives only when a condition holds.",
+		long: `The block is kept when <expr> is true and left out otherwise, before anything
+else in the design is read, so validate and plan show only what init would do.
+Words and quoted strings are compared with == and !=, a word on its own must
+be yes or no (true, yes, y, on, 1 or false, no, n, off, 0). exists(<path>),
+isfile(<path>), isdir(<path>) and ingit(<path>) test paths relative to the
+directory go-project runs in, as the disk is before the design runs. ingit is
+true inside a git work tree. onpath(<tool>) tests for a program on PATH.
+Tests combine with !, && and || and group with parentheses. A variable is one
+word whatever its value holds, ${os} and ${arch} are those go-project runs on
+and --var sets others. A variable that has no value is asked for when
+go-project runs in a terminal.`,
+		nesting: `Opens a block, its directives run in the directory of the enclosing block.
+Conditions can be nested.`,
+		example: "if: ${service} == true && !ingit(${project}) (\n    dir: ${project}/docker\n)",
+	},
+	{
+		name:    "else",
+		syntax:  []string{"else: (\n    <directives>\n)"},
+		short:   "Run a block of directives when the if: before it did not.",
+		long:    "The block is kept when the condition of the if: block just before it is false.",
+		nesting: "Follows the ) closing an if: block, on the same line or the next.",
+		example: "if: ${os} == windows (\n    copy: templates/run.bat\n)\nelse: (\n    copy: templates/run.sh\n)",
+	},
+}
+
 //────────────────────┤ getUsage ├────────────────────
 
+// getUsage is the help text boa builds the command line from
 func getUsage() string {
-	var help = `Usage: go-project [command] [flag...]
-	
-Commands:
-*+[help] <topic>          : Show help and exit, default command
-*[init]  <name>           : Create a project root directory with optional sub directories.
-		
-Flags:				
-[--design | -d] <design>  : File where project details are given. Details are listed in a text file. 
-	
-Long Description:
-init:       The init command with name will create a minimal project with only
-            a root directory and a readme file. More functionality can be had by
-            using a design file. See the 'example.design' file in this repository.
-			To use init without following with a name use -- for name
-
---design:   The project name is the minimum requirement, but can be followed
-            by sub directories with optional content specified for each one.
-            Some content may include a license, a .gitignore file, or any file 
-            you want copied into the directory. A README.md file can be created 
-            containing only a title line and optional text nested under the readme
-            option. External commands can be executed and nearly any structure you
-            want can be created. A workspace and/or module can be initialized as well.
-            The --design flag is optional, if not given the app will look for a file
-            named 'go-project.design' in the current directory. If that is not found then  
-            the program will exit with an exit code of 2.
-	 
-More:		
-`
-	return help
+	var b strings.Builder
+	b.WriteString("Usage: go-project [command] [flag...]\n\nCommands:\n")
+	for _, c := range commandHelp {
+		fmt.Fprintf(&b, "%-26s: %s\n", c.usage, c.short)
+	}
+	b.WriteString("\nFlags:\n")
+	for _, f := range flagHelp {
+		fmt.Fprintf(&b, "%-26s: %s\n", f.usage, f.short)
+	}
+	return b.String()
 }
 
 //────────────────────┤ ShowHelp ├────────────────────
 
-func ShowHelp(w io.Writer, command ...string) {
-	var help = `Usage: go-project [command] [flag...]
-	
-Commands:     
-[help] <topic>           : Show help and exit, default command
-[init] <name>            : Create a project root directory with optional sub directories.
-		
-Flags:				
-[--design | -d] <design> : File where project details are given. 
+// ShowHelp writes the help on topic to w, the summary when there is none.
+func ShowHelp(w io.Writer, topic ...string) error {
+	if len(topic) == 0 || topic[0] == "" || topic[0] == "--" {
+		fmt.Fprint(w, helpSummary())
+		return nil
+	}
+
+	switch topic[0] {
+	case "design":
+		fmt.Fprintf(w, "%s\n\nExample:\n%s\n", designHelp, indentText(designExample, "    "))
+		return nil
+	case "directives":
+		fmt.Fprint(w, directivesSummary())
+		return nil
+	case "directive":
+		if len(topic) < 2 {
+			fmt.Fprint(w, directivesSummary())
+			return nil
+		}
+		d, ok := findDirective(topic[1])
+		if !ok {
+			return fmt.Errorf("no directive named %s, see go-project help directives", topic[1])
+		}
+		fmt.Fprint(w, d.page())
+		return nil
+	case "exit-codes":
+		fmt.Fprintln(w, exitCodesHelp)
+		return nil
+	case "markdown":
+		fmt.Fprint(w, helpMarkdown())
+		return nil
+	}
+
+	if it, ok := findHelpItem(topic[0]); ok {
+		fmt.Fprint(w, it.page())
+		return nil
+	}
+	if d, ok := findDirective(topic[0]); ok {
+		fmt.Fprint(w, d.page())
+		return nil
+	}
+	return fmt.Errorf("no help on %s, see go-project help", topic[0])
+}
+
+//────────────────────┤ helpSummary ├────────────────────
+
+func helpSummary() string {
+	var b strings.Builder
+	b.WriteString("Usage: go-project [command] [flag...]\n\nCommands:\n")
+	for _, c := range commandHelp {
+		fmt.Fprintf(&b, "%-25s: %s\n", c.display(), c.short)
+	}
+	b.WriteString("\nFlags:\n")
+	for _, f := range flagHelp {
+		fmt.Fprintf(&b, "%-25s: %s\n", f.display(), f.short)
+	}
+	b.WriteString("\nTopics: a command or flag, design, directives, directive <name>,\nexit-codes, markdown\n")
+	b.WriteString("eg. go-project help init, go-project help directive dir\n")
+	return b.String()
+}
+
+func directivesSummary() string {
+	var b strings.Builder
+	b.WriteString("Design directives, see go-project help directive <name>:\n\n")
+	for _, d := range directivesHelp {
+		fmt.Fprintf(&b, "%-14s %s\n", d.name+":", d.short)
+	}
+	if kws := registeredKeywords(); len(kws) > 0 {
+		b.WriteString("\nAdded by this build of go-project:\n\n")
+		for _, kw := range kws {
+			fmt.Fprintf(&b, "%s:\n", kw)
+		}
+	}
+	return b.String()
+}
+
+//────────────────────┤ findHelpItem ├────────────────────
+
+// findHelpItem looks up a command or flag by name or alias, flags may be
+// given without their dashes.
+func findHelpItem(name string) (helpItem, bool) {
+	for _, it := range append(append([]helpItem{}, commandHelp...), flagHelp...) {
+		for _, n := range it.names() {
+			if n == name || strings.TrimLeft(n, "-") == name {
+				return it, true
+			}
+		}
+	}
+	return helpItem{}, false
+}
+
+func findDirective(name string) (directiveHelp, bool) {
+	name = strings.TrimSuffix(name, ":")
+	for _, d := range directivesHelp {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return directiveHelp{}, false
+}
+
+// names returns the name and alias of the item
+func (it helpItem) names() []string {
+	s := it.usage[strings.Index(it.usage, "[")+1 : strings.Index(it.usage, "]")]
+	return strings.Split(strings.ReplaceAll(s, " ", ""), "|")
+}
 
-Description:
-init:      
-The init command with name will create a minimal project with only a root directory and a
-readme file. More functionality can be had by using a design file. See the 'example.design'
-file in this repository. To use init without following with a name use -- for name.
-If no name is given, (eg. --) then a name must appear in the design file.
+// display is the usage without the meta characters boa reads
+func (it helpItem) display() string {
+	return strings.TrimLeft(it.usage, "*+#.")
+}
 
---design:
-The project name is the minimum requirement, but can be followed by sub directories with optional 
-content specified for each one. Some content may include a license, a .gitignore file,
-or any file you want copied into the directory. A README.md file can be created
-containing only a title line and optional text nested under the readme option.
-External commands can be executed and nearly any structure you want can be created. A workspace
-and/or module can be initiated as well.
-The --design flag is optional, if not given the app will look for a file named 'go-project.design'
-in the current directory. If that is not found then the program will exit with an exit code of 2.
-`
-	if len(command) != 0 {
-		fmt.Fprintf(w, "%s\n", command[0])
-		os.Exit(0)
-	} else {
-		fmt.Fprintln(w, help)
+func (it helpItem) page() string {
+	s := fmt.Sprintf("%s\n    %s\n", it.display(), it.short)
+	if it.long != "" {
+		s += "\n" + it.long + "\n"
 	}
+	return s
 }
 
+func (d directiveHelp) page() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n\nSyntax:\n", d.name, d.short)
+	for _, s := range d.syntax {
+		b.WriteString(indentText(s, "    ") + "\n")
+	}
+	if d.long != "" {
+		fmt.Fprintf(&b, "\n%s\n", d.long)
+	}
+	if d.nesting != "" {
+		fmt.Fprintf(&b, "\nNesting:\n%s\n", indentText(d.nesting, "    "))
+	}
+	if d.example != "" {
+		fmt.Fprintf(&b, "\nExample:\n%s\n", indentText(d.example, "    "))
+	}
+	return b.String()
+}
 
+func indentText(s, ind string) string {
+	return ind + strings.ReplaceAll(s, "\n", "\n"+ind)
+}
 
+//────────────────────┤ helpMarkdown ├────────────────────
 
+// helpMarkdown renders all of the help as the README of the repository
+func helpMarkdown() string {
+	var b strings.Builder
+	b.WriteString("<!-- Generated by go-project help markdown, edit help.go and run go generate. -->\n")
+	b.WriteString("# go-project\n\n## Usage: go-project [command] [flag...]\n\n## Commands:\n")
+	for _, c := range commandHelp {
+		fmt.Fprintf(&b, "`%s` : %s\n\n", c.display(), c.short)
+	}
+	b.WriteString("## Flags:\n")
+	for _, f := range flagHelp {
+		fmt.Fprintf(&b, "`%s` : %s\n\n", f.display(), f.short)
+	}
+
+	b.WriteString("## Description:\n")
+	for _, it := range append(append([]helpItem{}, commandHelp...), flagHelp...) {
+		if it.long == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "### %s\n%s\n\n", it.names()[0], quoteText(it.long))
+	}
+
+	fmt.Fprintf(&b, "## Design files\n%s\n\n```\n%s\n```\n\n", quoteText(designHelp), designExample)
 
+	fmt.Fprintf(&b, "## Exit codes\n```\n%s\n```\n\n", exitCodesHelp)
 
+	b.WriteString("## Directives\n")
+	for _, d := range directivesHelp {
+		fmt.Fprintf(&b, "### %s:\n%s\n\n```\n%s\n```\n\n", d.name, d.short, strings.Join(d.syntax, "\n"))
+		if d.long != "" {
+			fmt.Fprintf(&b, "%s\n\n", quoteText(d.long))
+		}
+		if d.nesting != "" {
+			fmt.Fprintf(&b, "Nesting: %s\n\n", strings.ReplaceAll(d.nesting, "\n", " "))
+		}
+		if d.example != "" {
+			fmt.Fprintf(&b, "```\n%s\n```\n\n", d.example)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// quoteText turns text into a markdown block quote
+func quoteText(s string) string {
+	return ">" + strings.ReplaceAll(s, "\n", "\n>")
+}
@@ -0,0 +1,196 @@
+package goproject
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	AppName        = "go-project"
+	LibraryDirName = "designs"
+)
+
+//─────────────┤ libraryDir ├─────────────
+
+// libraryDir is the per user design library, $XDG_CONFIG_HOME/go-project/designs
+// on Linux and the platform equivalent elsewhere.
+func libraryDir() (string, error) {
+	cfg, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cfg, AppName, LibraryDirName), nil
+}
+
+// the extensions of the designs kept in the library, a design is stored
+// in the format it was added in
+var libraryExts = []string{presetExt, presetExt + "." + FormatYAML, presetExt + ".yml", presetExt + "." + FormatTOML, presetExt + "." + FormatJSON}
+
+//─────────────┤ libraryName ├─────────────
+
+// libraryName is the name of the design in file, its base name without
+// the extension of a design
+func libraryName(file string) string {
+	name := filepath.Base(file)
+	for _, ext := range []string{".yaml", ".yml", ".toml", ".json"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	return strings.TrimSuffix(name, presetExt)
+}
+
+//─────────────┤ libraryPath ├─────────────
+
+// libraryPath is the file of the design called name in the library, in
+// whichever format it is stored, the native one when there is none yet.
+func libraryPath(name string) (string, error) {
+	name = libraryName(name)
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid design name %q", name)
+	}
+
+	dir, err := libraryDir()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range libraryExts {
+		if _, err := os.Stat(filepath.Join(dir, name+ext)); err == nil {
+			return filepath.Join(dir, name+ext), nil
+		}
+	}
+	return filepath.Join(dir, name+presetExt), nil
+}
+
+//─────────────┤ libraryNames ├─────────────
+
+func libraryNames() ([]string, error) {
+	dir, err := libraryDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, e := range entries {
+		for _, ext := range libraryExts {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ext) {
+				continue
+			}
+			if name := strings.TrimSuffix(e.Name(), ext); !seen[name] {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+//─────────────┤ resolveDesign ├─────────────
+
+// resolveDesign returns the design file to use for the --design value
+// desn. A file that exists as given is always used, otherwise a design of
+// that name in the user library is used when there is one.
+func resolveDesign(desn string) string {
+	if _, err := os.Stat(desn); err == nil {
+		return desn
+	}
+
+	lib, err := libraryPath(desn)
+	if err != nil {
+		return desn
+	}
+	if _, err := os.Stat(lib); err == nil {
+		return lib
+	}
+	return desn
+}
+
+//─────────────┤ doDesigns ├─────────────
+
+// doDesigns carries out the designs command. args is the action followed
+// by its parameters, the returned string is what should be printed.
+func doDesigns(args []string) (string, error) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		names, err := libraryNames()
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "", nil
+		}
+		return strings.Join(names, "\n") + "\n", nil
+	case "show":
+		if len(args) != 2 {
+			return "", errors.New("usage: designs show <name>")
+		}
+		lib, err := libraryPath(args[1])
+		if err != nil {
+			return "", err
+		}
+		b, err := os.ReadFile(lib)
+		if err != nil {
+			return "", fmt.Errorf("no design named %s in %s", args[1], filepath.Dir(lib))
+		}
+		return string(b), nil
+	case "add":
+		if len(args) < 2 || len(args) > 3 {
+			return "", errors.New("usage: designs add <file> [name]")
+		}
+		name := libraryName(args[1])
+		if len(args) == 3 {
+			name = libraryName(args[2])
+		}
+		lib, err := libraryPath(name)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(lib); err == nil {
+			return "", fmt.Errorf("a design named %s already exists, remove it first", name)
+		}
+		b, err := os.ReadFile(args[1])
+		if err != nil {
+			return "", err
+		}
+		if f, _ := designFormat(args[1], ""); f != FormatNative {
+			lib = strings.TrimSuffix(lib, presetExt) + presetExt + "." + f
+		}
+		if err := os.MkdirAll(filepath.Dir(lib), 0777); err != nil {
+			return "", err
+		}
+		return "", os.WriteFile(lib, b, 0666)
+	case "remove":
+		if len(args) != 2 {
+			return "", errors.New("usage: designs remove <name>")
+		}
+		lib, err := libraryPath(args[1])
+		if err != nil {
+			return "", err
+		}
+		if err := os.Remove(lib); err != nil {
+			return "", fmt.Errorf("no design named %s in %s", args[1], filepath.Dir(lib))
+		}
+		return "", nil
+	}
+
+	return "", fmt.Errorf("unknown designs action %s, use list, show, add or remove", args[0])
+}
@@ -0,0 +1,97 @@
+package goproject
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed licenses/*.txt
+var licenseFS embed.FS
+
+// licenseParams holds the license: directive, the SPDX identifier of the
+// license followed by an optional copyright holder.
+type licenseParams struct {
+	id, holder string
+}
+
+//─────────────┤ licenseNames ├─────────────
+
+func licenseNames() []string {
+	var names []string
+	entries, _ := fs.ReadDir(licenseFS, "licenses")
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".txt"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+//─────────────┤ parseLicenseParams ├─────────────
+
+func parseLicenseParams(text string) (licenseParams, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return licenseParams{}, fmt.Errorf("license: requires one of %s", strings.Join(licenseNames(), ", "))
+	}
+
+	for _, n := range licenseNames() {
+		if strings.EqualFold(n, fields[0]) {
+			holder := strings.Trim(strings.TrimPrefix(text, fields[0]), "\t \"")
+			return licenseParams{id: n, holder: holder}, nil
+		}
+	}
+	return licenseParams{}, fmt.Errorf("unknown license %s, use one of %s", fields[0], strings.Join(licenseNames(), ", "))
+}
+
+//─────────────┤ writeLicense ├─────────────
+
+func writeLicense(p *designParser, dir string, lp licenseParams) error {
+	file := filepath.Join(dir, "LICENSE")
+	if _, err := p.fs.Stat(file); err == nil {
+		err = fmt.Errorf("%s already exists", file)
+		p.setError(err)
+		return err
+	}
+
+	txt, err := renderLicense(lp, p.project)
+	if err != nil {
+		p.setError(fmt.Errorf("unknown license %s", lp.id))
+		return err
+	}
+
+	p.infof("write %s", file)
+	err = p.fs.WriteFile(file, txt, 0666)
+	if err != nil {
+		p.setError(fmt.Errorf("error writing %s", file))
+		return err
+	}
+
+	return nil
+}
+
+//─────────────┤ renderLicense ├─────────────
+
+// renderLicense fills in the license text, the holder defaults to project
+func renderLicense(lp licenseParams, project string) ([]byte, error) {
+	txt, err := licenseFS.ReadFile("licenses/" + lp.id + ".txt")
+	if err != nil {
+		return nil, err
+	}
+
+	holder := lp.holder
+	if holder == "" {
+		holder = project
+	}
+
+	var b bytes.Buffer
+	tmpl := template.Must(template.New(lp.id).Parse(string(txt)))
+	err = tmpl.Execute(&b, map[string]any{"Year": time.Now().Year(), "Holder": holder})
+	return b.Bytes(), err
+}
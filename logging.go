@@ -0,0 +1,173 @@
+package goproject
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	msg "github.com/westarver/messenger"
+)
+
+// Verbosity is how much is reported while a design is parsed and executed
+type Verbosity int
+
+const (
+	VerbosityQuiet  Verbosity = iota - 1 // errors only
+	VerbosityNormal                      // errors, failed steps and summaries
+	VerbosityInfo                        // every operation of every step, -v
+	VerbosityDebug                       // the workings of the parser too, -vv
+)
+
+// level names of the json log format
+var verbosityNames = map[Verbosity]string{
+	VerbosityQuiet:  "error",
+	VerbosityNormal: "notice",
+	VerbosityInfo:   "info",
+	VerbosityDebug:  "debug",
+}
+
+// logFunc receives the messages of the parser and executor
+type logFunc func(v Verbosity, format string, args ...any)
+
+func (d *designParser) infof(format string, args ...any) {
+	if d.logf != nil {
+		d.logf(VerbosityInfo, format, args...)
+	}
+}
+
+func (d *designParser) debugf(format string, args ...any) {
+	if d.logf != nil {
+		d.logf(VerbosityDebug, format, args...)
+	}
+}
+
+//─────────────┤ cliLog ├─────────────
+
+// cliLog sends what the command line reports through the messenger, at
+// the verbosity of the -v, -vv and --quiet flags and as text or json lines
+type cliLog struct {
+	writer *msg.Messenger
+	level  Verbosity
+	json   bool
+	out    io.Writer
+	file   *os.File
+}
+
+// newCLILog sets writer up for the verbosity, log file and format given.
+// Everything the messenger writes becomes a json line in the json format.
+func newCLILog(writer *msg.Messenger, level Verbosity, file, format string) (*cliLog, error) {
+	lg := &cliLog{writer: writer, level: level, out: writer.Out()}
+
+	switch format {
+	case "", "text":
+	case "json":
+		lg.json = true
+	default:
+		return lg, fmt.Errorf("unknown log format %s, use text or json", format)
+	}
+
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return lg, fmt.Errorf("unable to open log file %v", err)
+		}
+		lg.file, lg.out = f, f
+	}
+
+	out, logout := lg.out, lg.out
+	if lg.json {
+		writer.SetLoggerFlags(0)
+		writer.SetActionPrefix(msg.LOG, "%s")
+		writer.SetActionPrefix(msg.MESSAGE, "%s")
+		out = &jsonLines{w: lg.out, level: verbosityNames[VerbosityNormal]}
+		logout = &jsonLines{w: lg.out, level: verbosityNames[VerbosityQuiet]}
+	}
+	if level == VerbosityQuiet {
+		out = io.Discard
+	}
+	writer.SetOut(out)
+	writer.SetLogout(logout)
+	return lg, nil
+}
+
+// logf reports a message at verbosity v
+func (lg *cliLog) logf(v Verbosity, format string, args ...any) {
+	lg.record(v, nil, format, args...)
+}
+
+// record reports a message, with the step of e when it is not nil
+func (lg *cliLog) record(v Verbosity, e *Event, format string, args ...any) {
+	if v > lg.level {
+		return
+	}
+	text := fmt.Sprintf(format, args...)
+	if !lg.json {
+		lg.writer.InfoMsg(lg.writer.Logout(), msg.MESSAGE, "%s", text)
+		return
+	}
+
+	rec := map[string]any{"time": time.Now().Format(time.RFC3339Nano), "level": verbosityNames[v], "msg": text}
+	if e != nil {
+		rec["event"] = e.Kind
+		if e.Node != nil {
+			rec["step"] = e.Index + 1
+			rec["line"] = e.Node.Line
+			rec["command"] = e.Node.Command
+			rec["path"] = e.Node.Path
+		}
+		if e.Status != "" {
+			rec["status"] = e.Status
+		}
+		if e.Duration != 0 {
+			rec["duration_ms"] = e.Duration.Milliseconds()
+		}
+		if e.Err != nil {
+			rec["error"] = e.Err.Error()
+		}
+	}
+	b, _ := json.Marshal(rec)
+	lg.out.Write(append(b, '\n'))
+}
+
+// output passes on what the commands of a step print, to stdout as it is
+// in the text format and as a record of the step for each line in the json
+// format. --quiet leaves it out.
+func (lg *cliLog) output(e *Event) {
+	if !lg.json {
+		if lg.level >= VerbosityNormal {
+			fmt.Fprint(os.Stdout, e.Output)
+		}
+		return
+	}
+	for _, l := range strings.Split(strings.TrimSuffix(e.Output, "\n"), "\n") {
+		lg.record(VerbosityNormal, e, "%s", l)
+	}
+}
+
+func (lg *cliLog) close() {
+	if lg.file != nil {
+		lg.file.Close()
+	}
+}
+
+// jsonLines writes each message of the messenger as a json line
+type jsonLines struct {
+	w     io.Writer
+	level string
+}
+
+func (j *jsonLines) Write(b []byte) (int, error) {
+	rec := map[string]any{
+		"time":  time.Now().Format(time.RFC3339Nano),
+		"level": j.level,
+		"msg":   strings.TrimRight(string(b), "\n"),
+	}
+	out, _ := json.Marshal(rec)
+	if _, err := j.w.Write(append(out, '\n')); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
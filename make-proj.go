@@ -2,7 +2,10 @@ package goproject
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"github.com/bitfield/script"
@@ -11,18 +14,64 @@ import (
 
 //─────────────┤ initProject ├─────────────
 
-func initProject(name, desn string) (*designParser, error) {
-	//var trace = trace.New(os.Stderr)                                       //<rmv/>
-	//trace.Trace("----------------------------entering initProject\n")      //<rmv/>
-	//defer trace.Trace("----------------------------leaving initProject\n") //<rmv/>
-	//trace.Trace("project name as passed ", name)                           //<rmv/>
+func initProject(name, desn, format string, po parseOptions) (*designParser, error) {
 
-	p := script.Exec("xpanda " + desn)
-	dsn, err := p.Slice()
+	if _, err := os.Stat(desn); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDesignNotFound, desn)
+	}
+	format, err := designFormat(desn, format)
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := readDesign(desn)
 	if err != nil {
 		return nil, err
 	}
+	// yaml, toml and json designs are read as the native lines they stand for
+	dsn, err = designLines(strings.Join(dsn, "\n"), format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", desn, err)
+	}
+
+	return parseDesign(name, dsn, po)
+}
+
+//─────────────┤ readDesign ├─────────────
+
+// readDesign returns the lines of the design file desn after macro
+// expansion by xpanda.
+func readDesign(desn string) ([]string, error) {
+	p := script.Exec("xpanda " + desn)
+	return p.Slice()
+}
+
+//─────────────┤ parseDesign ├─────────────
+
+// parseDesign parses dsn with the user's config in the working directory,
+// the variables of po take the place of its settings
+func parseDesign(name string, dsn []string, po parseOptions) (*designParser, error) {
+	wd, err := path.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get working directory %v", err)
+	}
+
+	cfg, cerr := loadConfig()
+	for k, v := range po.vars {
+		cfg[k] = v
+	}
+	dp := parseDesignIn(name, dsn, wd.String(), cfg, po)
+	if cerr != nil {
+		dp.setError(cerr)
+	}
+	return dp, nil
+}
 
+//─────────────┤ parseDesignIn ├─────────────
+
+// parseDesignIn parses dsn as if it were executed in the absolute directory
+// root, with the settings of cfg as variables and defaults. po.logf, when
+// not nil, follows the parser and later the execution.
+func parseDesignIn(name string, dsn []string, root string, cfg config, po parseOptions) *designParser {
 	rs := mapFromPatSlice([]string{
 		BeginPattern,
 		ProjectPattern,
@@ -37,14 +86,34 @@ func initProject(name, desn string) (*designParser, error) {
 		ModulePattern,
 		WorkspacePattern,
 		GitInitPattern,
+		MainPattern,
+		PackagePattern,
+		TestPattern,
+		LicensePattern,
+		IfPattern,
+		ElsePattern,
 	})
 
 	if name == "--" {
 		name = ""
 	}
 
+	vars := map[string]string{}
+	for k, v := range cfg {
+		vars[k] = v
+	}
+	vars["project"] = designProject(name, dsn)
+	for k, v := range map[string]string{"os": runtime.GOOS, "arch": runtime.GOARCH} {
+		if _, ok := vars[k]; !ok {
+			vars[k] = v
+		}
+	}
+	src := dsn
+	dsn = expandVars(dsn, vars)
+
 	dp := designParser{
 		text:    dsn,
+		src:     src,
 		line:    0,
 		errs:    []error{},
 		project: name,
@@ -52,18 +121,32 @@ func initProject(name, desn string) (*designParser, error) {
 		regexs:  rs,
 		ast:     astQueue{},
 		nests:   nestStack{},
+		pkgs:    map[string]string{},
+		vars:    vars,
+		cfg:     cfg,
+		root:    root,
+		fs:      OSFS{},
+		runner:  ExecRunner{},
+		out:     os.Stdout,
+		logf:    po.logf,
+		ask:     po.ask,
+		answers: map[string]string{},
+	}
+	if po.fs != nil {
+		dp.fs = po.fs
 	}
 
-	wd, err := path.Getwd()
+	wd, err := path.New(root)
 	if err != nil {
-		dp.setError(fmt.Errorf("unable to get working directory %v", err))
-		return &dp, err
+		dp.setError(fmt.Errorf("invalid directory %s: %v", root, err))
+		return &dp
 	}
-
 	dp.nest.path = wd
 	dp.nest.limit = len(dsn)
 	dp.nest.nest = 0
 
+	dp.debugf("parsing %d lines for project %q in %s", len(dsn), vars["project"], root)
+
 	var atEOF bool
 	for {
 		l, eof := dp.nextline()
@@ -95,19 +178,28 @@ func initProject(name, desn string) (*designParser, error) {
 	}
 
 	if !atEOF {
+		dp.resolveConditions(start, dp.nest.limit)
 		dp.nests.push(nestLevel{})
 		scanBegin(&dp)
 	}
 
-	return &dp, nil
+	return &dp
+}
+
+//─────────────┤ expandPath ├─────────────
+
+// expandPath resolves a path of the design against the directory the
+// design is executed in
+func (d *designParser) expandPath(p string) (path.AbsPath, error) {
+	if !filepath.IsAbs(p) && !strings.HasPrefix(p, "~") {
+		p = filepath.Join(d.root, p)
+	}
+	return path.ExpandFrom(p)
 }
 
 //─────────────┤ scanBegin ├─────────────
 
 func scanBegin(d *designParser) {
-	//var trace = trace.New(os.Stderr)                                     //<rmv/>
-	//trace.Trace("----------------------------entering scanBegin\n")      //<rmv/>
-	//defer trace.Trace("----------------------------leaving scanBegin\n") //<rmv/>
 
 	_, eof := d.nextline()
 	if eof != nil {
@@ -128,9 +220,6 @@ type scanfunc func(*designParser) scanfunc
 //─────────────┤ scanCurrentLevel ├─────────────
 
 func scanCurrentLevel(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)                                            //<rmv/>
-	//trace.Trace("----------------------------entering scanCurrentLevel\n")      //<rmv/>
-	//defer trace.Trace("----------------------------leaving scanCurrentLevel\n") //<rmv/>
 	var reg *regexp.Regexp
 	ln, eof := d.current()
 	if eof != nil {
@@ -139,11 +228,13 @@ func scanCurrentLevel(d *designParser) scanfunc {
 
 	for _, r := range d.regexs {
 		if r.MatchString(ln) {
-			//trace.Trace("ln ", ln, " matched ", r.String()) //<rmv/>
 			reg = r
 			break
 		}
 	}
+	if reg != nil && reg != d.regexs[BlankPattern] && reg != d.regexs[CommentPattern] {
+		d.debugf("line %d at nesting level %d: %s", d.line+1, d.nest.nest, strings.TrimSpace(ln))
+	}
 
 	switch reg {
 	case d.regexs[BlankPattern]:
@@ -170,18 +261,26 @@ func scanCurrentLevel(d *designParser) scanfunc {
 		return scanModule
 	case d.regexs[GitInitPattern]:
 		return scanGitInit
+	case d.regexs[MainPattern]:
+		return scanMain
+	case d.regexs[PackagePattern]:
+		return scanPackage
+	case d.regexs[TestPattern]:
+		return scanTest
+	case d.regexs[LicensePattern]:
+		return scanLicense
 	}
-	//trace.Trace("no match for ", ln) //<rmv/>
-	return nil
+	if dr, ok := lookupDirective(ln); ok {
+		return scanCustom(dr)
+	}
+	d.setError(fmt.Errorf("unknown keyword at line %d: %s", d.line+1, strings.Trim(ln, "\t ")))
+	return scanBlank
 }
 
 //<rgn scanBlank>
 //─────────────┤ scanBlank ├─────────────
 
 func scanBlank(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)       //<rmv/>
-	//trace.Trace("entering scanBlank")      //<rmv/>
-	//defer trace.Trace("leaving scanBlank") //<rmv/>
 	_, eof := d.nextline()
 	if eof == nil {
 		return scanCurrentLevel
@@ -195,13 +294,9 @@ func scanBlank(d *designParser) scanfunc {
 //─────────────┤ scanProject ├─────────────
 
 func scanProject(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)         //<rmv/>
-	//trace.Trace("entering scanProject")      //<rmv/>
-	//defer trace.Trace("leaving scanProject") //<rmv/>
 	cur, eof := d.nextline()
 	if eof == nil {
 		r := regexStatFromPat(ProjectPattern, cur)
-		//trace.Trace("project match ", r.after) //<rmv/>
 		if r.after != "" {
 			// a name passed on the command line overrides the one in the design file
 			prj := strings.Trim(r.after, "\t ")
@@ -209,7 +304,6 @@ func scanProject(d *designParser) scanfunc {
 				d.project = prj
 			}
 		}
-		//trace.Trace("project name ", d.project) //<rmv/>
 		return scanCurrentLevel
 	}
 
@@ -220,21 +314,15 @@ func scanProject(d *designParser) scanfunc {
 //─────────────┤ scanExec ├─────────────
 
 func scanExec(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)      //<rmv/>
-	//trace.Trace("entering scanExec")      //<rmv/>
-	//defer trace.Trace("leaving scanExec") //<rmv/>
 	var bal bool
 	var n = -1
 
 	cur, eof := d.current()
 
 	if eof == nil {
-		//trace.Trace("cur from exec ", cur) //<rmv/>
 		r := regexStatFromPat(OpenPattern, cur)
 		if r.length > 0 {
-			//trace.Trace("parens found") //<rmv/>
 			n, bal = scanToClose(d)
-			//trace.Trace("multiline count ", n) //<rmv/>
 		}
 
 		if n == -1 { // no parentheses found
@@ -243,9 +331,8 @@ func scanExec(d *designParser) scanfunc {
 				cur = strings.Trim(r.after, "\t ")
 			}
 			// cmd, _ := shell.Split(cur)
-			//trace.Trace("exec command ", cmd) //<rmv/>
 			// arg := strings.Join(cmd[1:], " '")
-			d.ast.push(astNode{nest: d.nest, cmd: CmdExec, cmdParams: cur})
+			d.ast.push(astNode{nest: d.nest, line: d.line + 1, cmd: CmdExec, cmdParams: cur})
 			d.line++
 			return scanCurrentLevel
 		}
@@ -259,7 +346,6 @@ func scanExec(d *designParser) scanfunc {
 			//arg := getMultilineQuotedStr(d.text[startLn : startLn+n])
 			cur = strings.Join(d.text[startLn:startLn+n], "\n")
 			cur = stripParens(cur)
-			//trace.Trace("after strip ", cur) //<rmv/>
 			r := regexStatFromPat(ExecPattern, cur)
 			if r.length > 0 {
 				cur = strings.Trim(r.after, "\t ")
@@ -269,9 +355,8 @@ func scanExec(d *designParser) scanfunc {
 			// 	d.setError(fmt.Errorf("unbalanced quotes or backslashes in exec command near line %d", startLn+n))
 			// 	return nil
 			// }
-			//trace.Trace("exec command ", args) //<rmv/>
 			// arg := strings.Join(args[1:], " ")
-			d.ast.push(astNode{nest: d.nest, cmd: CmdExec, cmdParams: cur})
+			d.ast.push(astNode{nest: d.nest, line: d.line + 1, cmd: CmdExec, cmdParams: cur})
 			d.line += n
 			return scanCurrentLevel
 		}
@@ -279,14 +364,12 @@ func scanExec(d *designParser) scanfunc {
 		if n == 0 { //parentheses found and closed on one line
 			if bal {
 				cur = stripParens(cur)
-				//trace.Trace("cur after strip ", cur) //<rmv/>
 				r := regexStatFromPat(ExecPattern, cur)
 				if r.length > 0 {
 					cur = strings.Trim(r.after, "\t ")
 				}
 
-				d.ast.push(astNode{nest: d.nest, cmd: CmdExec, cmdParams: cur})
-				//trace.Trace("exec command ", cur) //<rmv/>
+				d.ast.push(astNode{nest: d.nest, line: d.line + 1, cmd: CmdExec, cmdParams: cur})
 				d.line++
 				return scanCurrentLevel
 			} else {
@@ -302,10 +385,6 @@ func scanExec(d *designParser) scanfunc {
 //─────────────┤ scanDir ├─────────────
 // scanDir is the only scanner that has to deal with multiple nesting levels
 func scanDir(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)                                     //<rmv/>
-	//trace.Trace("------------------------------entering scanDir\n")      //<rmv/>
-	//defer trace.Trace("\n----------------------------leaving scanDir\n") //<rmv/>
-	//trace.Trace("current ", d.text[d.line]) //<rmv/>
 	var bal bool
 	var n = -1
 
@@ -318,23 +397,19 @@ func scanDir(d *designParser) scanfunc {
 		if r.length > 0 {
 			cur = strings.Trim(r.after, "\t ")
 		}
-		//trace.Trace("cur from dir ", cur) //<rmv/>
 
 		r = regexStatFromPat(OpenPattern, cur)
 		if r.length > 0 {
-			//trace.Trace("parens found") //<rmv/>
 			n, bal = scanToClose(d)
-			//trace.Trace("multiline count ", n) //<rmv/>
 		}
 
 		if n == -1 { // no parentheses found
-			path, err := path.ExpandFrom(cur)
+			path, err := d.expandPath(cur)
 			if err != nil {
 				d.setError(fmt.Errorf("invalid path given at line %d", d.line))
 				return nil
 			}
-			d.ast.push(astNode{nest: d.nest, cmd: CmdDir, cmdParams: path})
-			//trace.Trace("new dir ", path) //<rmv/>
+			d.ast.push(astNode{nest: d.nest, line: d.line + 1, cmd: CmdDir, cmdParams: path})
 			d.line++
 			return scanCurrentLevel
 		}
@@ -346,15 +421,13 @@ func scanDir(d *designParser) scanfunc {
 			}
 
 			cur = strings.Trim(stripParens(cur), "\t ")
-			path, err := path.ExpandFrom(cur)
+			path, err := d.expandPath(cur)
 			if err != nil {
 				d.setError(fmt.Errorf("invalid path given at line %d", d.line))
 				return nil
 			}
 			d.nesting(1, startLn+n, path)
-			//trace.Trace("new nesting level ", d.nest.nest, " limit ", startLn+n, " path ", path) //<rmv/>
-			d.ast.push(astNode{nest: d.nest, cmd: CmdDir, cmdParams: path})
-			//trace.Trace("new dir ", path.String()) //<rmv/>
+			d.ast.push(astNode{nest: d.nest, line: d.line + 1, cmd: CmdDir, cmdParams: path})
 			d.line++
 			return scanCurrentLevel
 		}
@@ -366,13 +439,12 @@ func scanDir(d *designParser) scanfunc {
 			}
 
 			cur = strings.Trim(stripParens(cur), "\t ")
-			path, err := path.ExpandFrom(cur)
+			path, err := d.expandPath(cur)
 			if err != nil {
 				d.setError(fmt.Errorf("invalid path given at line %d", d.line))
 				return nil
 			}
-			d.ast.push(astNode{nest: d.nest, cmd: CmdDir, cmdParams: path})
-			//trace.Trace("new dir ", path.String()) //<rmv/>
+			d.ast.push(astNode{nest: d.nest, line: d.line + 1, cmd: CmdDir, cmdParams: path})
 			d.line++
 			return scanCurrentLevel
 		}
@@ -384,19 +456,14 @@ func scanDir(d *designParser) scanfunc {
 //─────────────┤ scanCopy ├─────────────
 
 func scanCopy(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)      //<rmv/>
-	//trace.Trace("entering scanCopy")      //<rmv/>
-	//defer trace.Trace("leaving scanCopy") //<rmv/>
 	cur, eof := d.nextline()
-	//trace.Trace("current line in scanCopy ", cur) //<rmv/>
 	if eof == nil {
 		r := regexStatFromPat(CopyPattern, cur)
 		if r.length == 0 {
 			d.setError(fmt.Errorf("landed in scanCopy but did not match CopyPattern"))
 			return nil
 		}
-		//trace.Trace("copy source ", strings.Trim(r.after, "\t ")) //<rmv/>
-		d.ast.push(astNode{nest: d.nest, cmd: CmdCopy, cmdParams: strings.Trim(r.after, "\t ")})
+		d.ast.push(astNode{nest: d.nest, line: d.line, cmd: CmdCopy, cmdParams: strings.Trim(r.after, "\t ")})
 		return scanCurrentLevel
 	} else {
 		d.setError(fmt.Errorf("unexpected EOF at line %d", d.line))
@@ -409,18 +476,14 @@ func scanCopy(d *designParser) scanfunc {
 //─────────────┤ scanGet ├─────────────
 
 func scanGet(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)     //<rmv/>
-	//trace.Trace("entering scanGet")      //<rmv/>
-	//defer trace.Trace("leaving scanGet") //<rmv/>
 	cur, eof := d.nextline()
-	//trace.Trace("current line in scanGet ", cur) //<rmv/>
 	if eof == nil {
 		r := regexStatFromPat(GetPattern, cur)
 		if r.length == 0 {
 			d.setError(fmt.Errorf("landed in scanGet but did not match GetPattern"))
 			return nil
 		}
-		d.ast.push(astNode{nest: d.nest, cmd: CmdGet, cmdParams: strings.Trim(r.after, "\t ")})
+		d.ast.push(astNode{nest: d.nest, line: d.line, cmd: CmdGet, cmdParams: strings.Trim(r.after, "\t ")})
 		return scanCurrentLevel
 	} else {
 		d.setError(fmt.Errorf("unexpected EOF at line %d", d.line))
@@ -433,34 +496,27 @@ func scanGet(d *designParser) scanfunc {
 //─────────────┤ scanWorkspace ├─────────────
 
 func scanWorkspace(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)           //<rmv/>
-	//trace.Trace("entering scanWorkspace")      //<rmv/>
-	//defer trace.Trace("leaving scanWorkspace") //<rmv/>
-	cur, eof := d.nextline()
-	if eof == nil {
-		r := regexStatFromPat(WorkspacePattern, cur)
-		if r.length == 0 {
-			d.setError(fmt.Errorf("landed in scanWorkspace but did not match Workspace Pattern"))
-			return nil
-		}
-		cur = strings.Trim(r.after, "\t ")
-		//trace.Trace("workspace params ", cur) //<rmv/>
-		d.ast.push(astNode{nest: d.nest, cmd: CmdWorkspace, cmdParams: cur})
-		return scanCurrentLevel
-	} else {
-		d.setError(fmt.Errorf("unexpected EOF at line %d", d.line))
+	startLn := d.line
+	cur, n, ok := directiveText(d, WorkspacePattern)
+	if !ok {
+		return nil
 	}
 
-	return nil
+	wp, err := parseWorkspaceParams(cur)
+	if err != nil {
+		d.setError(fmt.Errorf("%v at line %d", err, startLn))
+		return nil
+	}
+
+	d.ast.push(astNode{nest: d.nest, line: startLn + 1, cmd: CmdWorkspace, cmdParams: wp})
+	d.line += n
+	return scanCurrentLevel
 } //</rgn scanWorkspace>
 
 //<rgn scanModule>
 //─────────────┤ scanModule ├─────────────
 
 func scanModule(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)        //<rmv/>
-	//trace.Trace("entering scanModule")      //<rmv/>
-	//defer trace.Trace("leaving scanModule") //<rmv/>
 	cur, eof := d.nextline()
 	if eof == nil {
 		r := regexStatFromPat(ModulePattern, cur)
@@ -470,8 +526,14 @@ func scanModule(d *designParser) scanfunc {
 		}
 
 		cur = strings.Trim(r.after, "\t ")
-		//trace.Trace("module name ", cur) //<rmv/>
-		d.ast.push(astNode{nest: d.nest, cmd: CmdModule, cmdParams: cur})
+		if cur == "" { // module.prefix followed by the path of the directory
+			rel := relPath(d.root, d.nest.path.String())
+			if rel == "." {
+				rel = d.project
+			}
+			cur = d.cfg.modulePath(rel)
+		}
+		d.ast.push(astNode{nest: d.nest, line: d.line, cmd: CmdModule, cmdParams: cur})
 		return scanCurrentLevel
 	} else {
 		d.setError(fmt.Errorf("unexpected EOF at line %d", d.line))
@@ -484,49 +546,248 @@ func scanModule(d *designParser) scanfunc {
 //─────────────┤ scanGitInit ├─────────────
 
 func scanGitInit(d *designParser) scanfunc {
-	//var trace = trace.New(os.Stderr)         //<rmv/>
-	//trace.Trace("entering scanGitInit")      //<rmv/>
-	//defer trace.Trace("leaving scanGitInit") //<rmv/>
-	_, eof := d.nextline()
-	if eof == nil {
-		//trace.Trace("git init ", cur) //<rmv/>
-		d.ast.push(astNode{nest: d.nest, cmd: CmdGitInit, cmdParams: true})
-		return scanCurrentLevel
-	} else {
-		d.setError(fmt.Errorf("unexpected EOF at line %d", d.line))
+	startLn := d.line
+	cur, n, ok := directiveText(d, GitInitPattern)
+	if !ok {
+		return nil
 	}
 
-	return nil
+	gp, err := parseGitInitParams(cur)
+	if err != nil {
+		d.setError(fmt.Errorf("%v at line %d", err, startLn))
+		return nil
+	}
+	gp.defaults(d.cfg)
+
+	d.ast.push(astNode{nest: d.nest, line: startLn + 1, cmd: CmdGitInit, cmdParams: gp})
+	d.line += n
+	return scanCurrentLevel
 } //</rgn scanGitInit>
 
+//<rgn scanMain>
+//─────────────┤ scanMain ├─────────────
+
+func scanMain(d *designParser) scanfunc {
+	startLn := d.line
+	cur, n, ok := directiveText(d, MainPattern)
+	if !ok {
+		return nil
+	}
+
+	sp := sourceParams{pkg: "main", file: "main.go"}
+	switch cur {
+	case "":
+	case "cli":
+		sp.cli = true
+	default:
+		d.setError(fmt.Errorf("main: unknown option %s at line %d", cur, startLn))
+		return nil
+	}
+
+	if !d.declarePackage(sp.pkg, startLn) {
+		return nil
+	}
+	d.ast.push(astNode{nest: d.nest, line: startLn + 1, cmd: CmdMain, cmdParams: sp})
+	d.line += n
+	return scanCurrentLevel
+} //</rgn scanMain>
+
+//<rgn scanPackage>
+//─────────────┤ scanPackage ├─────────────
+
+func scanPackage(d *designParser) scanfunc {
+	startLn := d.line
+	cur, n, ok := directiveText(d, PackagePattern)
+	if !ok {
+		return nil
+	}
+
+	pkg := cur
+	if pkg == "" {
+		pkg = packageFromDir(d.nest.path.String())
+	}
+	if !isPackageName(pkg) {
+		d.setError(fmt.Errorf("invalid package name %q at line %d", pkg, startLn))
+		return nil
+	}
+
+	if !d.declarePackage(pkg, startLn) {
+		return nil
+	}
+	d.ast.push(astNode{nest: d.nest, line: startLn + 1, cmd: CmdPackage, cmdParams: sourceParams{pkg: pkg, file: "doc.go"}})
+	d.line += n
+	return scanCurrentLevel
+} //</rgn scanPackage>
+
+// declarePackage records pkg as the package of the current directory, a
+// directory declared as another package before is an error
+func (d *designParser) declarePackage(pkg string, line int) bool {
+	dir := d.nest.path.String()
+	if prev, ok := d.pkgs[dir]; ok && prev != pkg {
+		d.setError(fmt.Errorf("package %s at line %d conflicts with package %s already declared in %s", pkg, line, prev, dir))
+		return false
+	}
+	d.pkgs[dir] = pkg
+	return true
+}
+
+//<rgn scanTest>
+//─────────────┤ scanTest ├─────────────
+
+func scanTest(d *designParser) scanfunc {
+	startLn := d.line
+	cur, n, ok := directiveText(d, TestPattern)
+	if !ok {
+		return nil
+	}
+
+	pkg, declared := d.pkgs[d.nest.path.String()]
+	if !declared {
+		pkg = packageFromDir(d.nest.path.String())
+	}
+	if !isPackageName(pkg) {
+		d.setError(fmt.Errorf("invalid package name %q derived from %s at line %d", pkg, d.nest.path.String(), startLn))
+		return nil
+	}
+
+	name := cur
+	if name == "" {
+		name = pkg
+	}
+	if !isPackageName(strings.ReplaceAll(name, "-", "_")) {
+		d.setError(fmt.Errorf("invalid test name %q at line %d", name, startLn))
+		return nil
+	}
+
+	d.ast.push(astNode{nest: d.nest, line: startLn + 1, cmd: CmdTest, cmdParams: sourceParams{pkg: pkg, file: name + "_test.go", name: name}})
+	d.line += n
+	return scanCurrentLevel
+} //</rgn scanTest>
+
+//<rgn scanLicense>
+//─────────────┤ scanLicense ├─────────────
+
+func scanLicense(d *designParser) scanfunc {
+	startLn := d.line
+	cur, n, ok := directiveText(d, LicensePattern)
+	if !ok {
+		return nil
+	}
+
+	if cur == "" {
+		cur = d.cfg["license"]
+	}
+	lp, err := parseLicenseParams(cur)
+	if err != nil {
+		d.setError(fmt.Errorf("%v at line %d", err, startLn))
+		return nil
+	}
+	if lp.holder == "" {
+		lp.holder = d.cfg.holder()
+	}
+
+	d.ast.push(astNode{nest: d.nest, line: startLn + 1, cmd: CmdLicense, cmdParams: lp})
+	d.line += n
+	return scanCurrentLevel
+} //</rgn scanLicense>
+
 //------Utility functions ------
 
+//─────────────┤ designProject ├─────────────
+
+// designProject returns the project name in effect for a design, the name
+// passed on the command line wins over a project: line in the design.
+func designProject(name string, dsn []string) string {
+	if name != "" {
+		return name
+	}
+
+	for _, l := range dsn {
+		r := regexStatFromPat(ProjectPattern, l)
+		if r.length > 0 {
+			return strings.Trim(r.after, "\t ")
+		}
+	}
+	return ""
+}
+
+//─────────────┤ expandVars ├─────────────
+
+// expandVars replaces ${name} with its value for every name in vars.
+// Anything else that looks like a variable is left for the shell.
+func expandVars(dsn []string, vars map[string]string) []string {
+	var pairs []string
+	for k, v := range vars {
+		pairs = append(pairs, "${"+k+"}", v)
+	}
+	rep := strings.NewReplacer(pairs...)
+
+	ret := make([]string, len(dsn))
+	for i, l := range dsn {
+		ret[i] = rep.Replace(l)
+	}
+	return ret
+}
+
 //─────────────┤ stripParens ├─────────────
 
 func stripParens(line string) string {
-	//var trace = trace.New(os.Stderr)                                         //<rmv/>
-	//trace.Trace("----------------------------entering stripParens\n")        //<rmv/>
-	//defer trace.Trace("\n----------------------------leaving stripParens\n") //<rmv/>
 	m := strings.Index(line, "(")
 	if m >= 0 {
 		line = line[:m] + line[m+1:]
-		//trace.Trace("strip first ( leaves", line) //<rmv/>
 	}
 	n := strings.LastIndex(line, ")")
 	if n > 1 {
 		line = line[:n] + line[n+1:]
 	}
-	//trace.Trace("strip all ( ) leaves", line) //<rmv/>
 	return line
 }
 
+//─────────────┤ directiveText ├─────────────
+
+// directiveText returns the text following the keyword matched by pat on
+// the current line. If the text opens a parenthesis, everything up to the
+// matching close is returned with the parentheses removed. The line count
+// returned is the number of lines the directive occupies.
+func directiveText(d *designParser, pat string) (string, int, bool) {
+	var bal bool
+	var n = -1
+
+	cur, eof := d.current()
+	if eof != nil {
+		d.setError(fmt.Errorf("unexpected EOF at line %d", d.line))
+		return "", 0, false
+	}
+
+	r := regexStatFromPat(pat, cur)
+	if r.length == 0 {
+		d.setError(fmt.Errorf("expected %s at line %d", pat, d.line))
+		return "", 0, false
+	}
+
+	r = regexStatFromPat(OpenPattern, r.after)
+	if r.length > 0 {
+		n, bal = scanToClose(d)
+		if !bal {
+			d.setError(fmt.Errorf("unbalanced parentheses near line %d", d.line+n))
+			return "", 0, false
+		}
+	}
+
+	if n > 0 {
+		cur = stripParens(strings.Join(d.text[d.line:d.line+n], "\n"))
+	} else {
+		n = 1
+	}
+
+	r = regexStatFromPat(pat, cur)
+	return strings.Trim(r.after, "\t "), n, true
+}
+
 //<rgn scanToClose>───────────────────────────────────
 // scanToClose expects to receive the remaining text starting at the char
 // after the keyword to eof
 func scanToClose(d *designParser) (int, bool) {
-	//var trace = trace.New(os.Stderr)         //<rmv/>
-	//trace.Trace("entering scanToClose")      //<rmv/>
-	//defer trace.Trace("leaving scanToClose") //<rmv/>
 	parens := 0
 	var r regexStat
 	found := false
@@ -543,7 +804,6 @@ func scanToClose(d *designParser) (int, bool) {
 		if r.length > 0 {
 			parens--
 			if parens == 0 {
-				//trace.Trace("lines between ()", d.text[d.line:d.line+n]) //<rmv/>
 				return n, true
 			}
 		}
@@ -0,0 +1,181 @@
+package goproject
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	path "github.com/rhysd/abspath"
+)
+
+// Node is one step of a design as plan and Design.Nodes show it. Paths are
+// relative to the directory the design is executed in so that plans made
+// on different machines can be diffed.
+type Node struct {
+	Line    int            `json:"line"`
+	Depth   int            `json:"depth"`
+	Command string         `json:"command"`
+	Path    string         `json:"path"`
+	Params  map[string]any `json:"params"`
+}
+
+type plan struct {
+	Design  string `json:"design"`
+	Project string `json:"project"`
+	Root    string `json:"-"` // kept out of json so plans made elsewhere diff clean
+	Nodes   []Node `json:"nodes"`
+}
+
+//─────────────┤ makePlan ├─────────────
+
+func makePlan(desn string, p *designParser) plan {
+	pl := plan{Design: desn, Project: p.project, Root: p.root, Nodes: []Node{}}
+
+	for _, n := range p.ast.q {
+		depth := n.nest.nest
+		// a dir that opens a block is pushed after its own nesting level
+		if n.cmd == CmdDir && depth > 0 && n.cmdParams.(path.AbsPath).String() == n.nest.path.String() {
+			depth--
+		}
+
+		pl.Nodes = append(pl.Nodes, Node{
+			Line:    n.line,
+			Depth:   depth,
+			Command: n.keyword(),
+			Path:    relPath(pl.Root, n.nest.path.String()),
+			Params:  nodeParams(pl.Root, n),
+		})
+	}
+
+	return pl
+}
+
+//─────────────┤ nodeParams ├─────────────
+
+func nodeParams(root string, n astNode) map[string]any {
+	switch prm := n.cmdParams.(type) {
+	case path.AbsPath:
+		return map[string]any{"dir": relPath(root, prm.String())}
+	case workspaceParams:
+		return map[string]any{"go": prm.goVersion, "use": prm.use, "replace": prm.replace}
+	case gitInitParams:
+		m := map[string]any{}
+		if prm.branch != "" {
+			m["branch"] = prm.branch
+		}
+		if prm.commit {
+			m["commit"] = prm.message
+		}
+		if prm.userName != "" {
+			m["user.name"] = prm.userName
+		}
+		if prm.userEmail != "" {
+			m["user.email"] = prm.userEmail
+		}
+		if prm.remoteURL != "" {
+			m["remote"] = prm.remoteName + " " + prm.remoteURL
+		}
+		if len(prm.attributes) > 0 {
+			m["attributes"] = prm.attributes
+		}
+		for _, h := range prm.hooks {
+			m["hook "+h.name] = h.src
+		}
+		return m
+	case sourceParams:
+		m := map[string]any{"package": prm.pkg, "file": prm.file}
+		if prm.cli {
+			m["cli"] = true
+		}
+		return m
+	case licenseParams:
+		return map[string]any{"license": prm.id, "holder": prm.holder}
+	case customParams:
+		return map[string]any{"description": prm.d.Describe(prm.params)}
+	case string:
+		key := map[CommandToken]string{CmdExec: "command", CmdCopy: "sources", CmdGet: "url", CmdModule: "module"}[n.cmd]
+		return map[string]any{key: prm}
+	}
+
+	return map[string]any{}
+}
+
+//─────────────┤ format ├─────────────
+
+func (pl plan) format(form string) (string, error) {
+	switch form {
+	case "", "tree", "text":
+		var b strings.Builder
+		fmt.Fprintf(&b, "design  %s\nproject %s\nroot    %s\n\n", pl.Design, pl.Project, pl.Root)
+		for _, n := range pl.Nodes {
+			fmt.Fprintf(&b, "%4d  %s%s: %s", n.Line, strings.Repeat("    ", n.Depth), n.Command, paramsText(n.Params))
+			if n.Command != CmdDir.String() {
+				fmt.Fprintf(&b, "  [in %s]", n.Path)
+			}
+			b.WriteString("\n")
+		}
+		return b.String(), nil
+	case "json":
+		return marshalJSON(pl)
+	}
+
+	return "", fmt.Errorf("unknown format %s, use tree or json", form)
+}
+
+//─────────────┤ marshalJSON ├─────────────
+
+// marshalJSON indents v and leaves characters such as > in commands alone
+func marshalJSON(v any) (string, error) {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	return b.String(), err
+}
+
+//─────────────┤ paramsText ├─────────────
+
+func paramsText(m map[string]any) string {
+	if len(m) == 1 {
+		for k, v := range m {
+			if k != "cli" {
+				return fmt.Sprint(v)
+			}
+		}
+	}
+
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		v := fmt.Sprint(m[k])
+		if s, ok := m[k].([]string); ok {
+			v = strings.Join(s, ",")
+		}
+		if v == "" {
+			continue
+		}
+		if strings.ContainsAny(v, " \t") {
+			v = fmt.Sprintf("%q", v)
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
+
+//─────────────┤ relPath ├─────────────
+
+func relPath(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
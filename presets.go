@@ -0,0 +1,78 @@
+package goproject
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed presets/*.design
+var presetFS embed.FS
+
+const presetExt = ".design"
+
+//─────────────┤ presetNames ├─────────────
+
+func presetNames() []string {
+	var names []string
+	entries, _ := fs.ReadDir(presetFS, "presets")
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), presetExt))
+	}
+	sort.Strings(names)
+	return names
+}
+
+//─────────────┤ presetSource ├─────────────
+
+// presetSource returns the design text of a built-in preset exactly as it
+// is embedded, suitable for saving and editing as a custom design.
+func presetSource(name string) (string, error) {
+	b, err := presetFS.ReadFile("presets/" + name + presetExt)
+	if err != nil {
+		return "", fmt.Errorf("no preset named %s, choose one of %s", name, strings.Join(presetNames(), ", "))
+	}
+	return string(b), nil
+}
+
+//─────────────┤ presetDesign ├─────────────
+
+func presetDesign(name string) ([]string, error) {
+	src, err := presetSource(name)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(src, "\n"), "\n"), nil
+}
+
+//─────────────┤ presetSummary ├─────────────
+
+// presetSummary is the first comment in the preset, which by convention
+// reads "name: description".
+func presetSummary(name string) string {
+	dsn, err := presetDesign(name)
+	if err != nil {
+		return ""
+	}
+
+	for _, l := range dsn {
+		r := regexStatFromPat(CommentPattern, l)
+		if r.length > 0 {
+			s := strings.Trim(r.after, "\t ")
+			return strings.Trim(strings.TrimPrefix(s, name+":"), "\t ")
+		}
+	}
+	return ""
+}
+
+//─────────────┤ listPresets ├─────────────
+
+func listPresets() string {
+	var b strings.Builder
+	for _, n := range presetNames() {
+		fmt.Fprintf(&b, "%-12s %s\n", n, presetSummary(n))
+	}
+	return b.String()
+}
@@ -1,7 +1,14 @@
 package goproject
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/westarver/boa"
 	msg "github.com/westarver/messenger"
@@ -11,43 +18,144 @@ const (
 	DefaultCfgFile = "go-project.design"
 )
 
+// exit codes of Run
+const (
+	ExitOK             = 0
+	ExitUsage          = 1   // bad command line, or a command other than init failed
+	ExitDesignNotFound = 2   // the design file or preset does not exist or cannot be read
+	ExitInvalidDesign  = 3   // the design has errors
+	ExitDesignWarnings = 4   // validate found warnings but no errors
+	ExitDesignDrift    = 5   // diff found things to create or that differ, or fmt --diff found changes
+	ExitExecution      = 6   // init failed at every step it carried out
+	ExitPartial        = 7   // init carried out some steps and failed at others
+	ExitInterrupted    = 130 // init was interrupted by SIGINT or SIGTERM
+)
+
+// ErrDesignNotFound is returned when the design to load does not exist
+var ErrDesignNotFound = errors.New("design not found")
+
+// Run carries out the command line in os.Args
 func Run(writer *msg.Messenger) int {
+	return RunContext(context.Background(), writer)
+}
+
+//─────────────┤ RunContext ├─────────────
+
+// RunContext is Run with ctx governing execution, init stops once ctx is
+// done and reports which steps were carried out.
+func RunContext(ctx context.Context, writer *msg.Messenger) int {
 	var (
 		cfg      string
 		exitCode int
+		summary  string
 	)
 
+	os.Args = splitFlagValues(os.Args)
 	cli := boa.FromHelp(getUsage())
+	if e := cli.Errors(); e != "" {
+		writer.Catch(msg.LOG, errors.New(e))
+		return ExitUsage
+	}
+
+	level := VerbosityNormal
+	if _, ok := cli.Items["--verbose"].(boa.CmdLineItem[bool]); ok {
+		level = VerbosityInfo
+	}
+	if _, ok := cli.Items["--debug"].(boa.CmdLineItem[bool]); ok {
+		level = VerbosityDebug
+	}
+	if _, ok := cli.Items["--quiet"].(boa.CmdLineItem[bool]); ok {
+		level = VerbosityQuiet
+	}
+	var logFile, logFormat string
+	if lf, ok := cli.Items["--log-file"].(boa.CmdLineItem[string]); ok {
+		logFile = lf.Value()
+	}
+	if lf, ok := cli.Items["--log-format"].(boa.CmdLineItem[string]); ok {
+		logFormat = lf.Value()
+	}
+	lg, err := newCLILog(writer, level, logFile, logFormat)
+	defer lg.close()
+	if err != nil {
+		writer.Catch(msg.LOG, err)
+		return ExitUsage
+	}
 
-	help, hlp := cli.Items["help"].(boa.CmdLineItem[string])
+	help, hlp := cli.Items["help"].(boa.CmdLineItem[[]string])
 	if hlp {
-		topic := help.Value()
-		item, exist := cli.AllHelp[topic]
-		if exist {
-			ShowHelp(writer, item)
-		} else {
-			ShowHelp(writer)
+		if err := ShowHelp(os.Stdout, help.Value()...); err != nil {
+			writer.Catch(msg.LOG, err)
+			return ExitUsage
 		}
-		return 0
+		return ExitOK
 	}
 
 	file, f := cli.Items["--design"].(boa.CmdLineItem[string])
 	if f {
-		cfg = file.Value()
+		cfg = resolveDesign(file.Value())
 	} else {
-		cfg = DefaultCfgFile
+		cfg = defaultDesign()
+	}
+	var presetName string
+	preset, pre := cli.Items["--preset"].(boa.CmdLineItem[string])
+	if pre {
+		presetName = preset.Value()
+	}
+	var form, designForm string
+	if ff, ok := cli.Items["--format"].(boa.CmdLineItem[string]); ok {
+		form = ff.Value()
+	}
+	if df, ok := cli.Items["--design-format"].(boa.CmdLineItem[string]); ok {
+		designForm = df.Value()
+	}
+	po := parseOptions{logf: lg.logf}
+	if vs, ok := cli.Items["--var"].(boa.CmdLineItem[string]); ok {
+		if po.vars, err = parseVars(vs.Value()); err != nil {
+			writer.Catch(msg.LOG, err)
+			return ExitUsage
+		}
+	}
+	if isTerminal(os.Stdin) {
+		po.ask = promptVar(os.Stdin, writer.Logout())
 	}
 	in, init := cli.Items["init"].(boa.CmdLineItem[string])
 	if init {
 		name := in.Value()
-		parser, err := initProject(name, cfg)
-		if err != nil {
+		parser, err := loadDesign(name, cfg, presetName, designForm, po)
+		switch {
+		case err != nil:
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitDesignNotFound
+		case pre && (name == "" || name == "--"):
+			writer.Catch(msg.LOG, errors.New("a project name is required to init from a preset"))
+			exitCode = ExitUsage
+		case parser.hasErrors():
+			// nothing is created from a design that does not parse
 			writer.LogMsg(writer.Logout(), 1, parser.Errors()+"\n")
-			exitCode = 2
+			exitCode = ExitInvalidDesign
+			summary = "init: design has errors, nothing done"
+		default:
+			parser.events = cliEvents(lg)
+			sum := executeAst(ctx, parser, false)
+			if parser.hasErrors() {
+				writer.LogMsg(writer.Logout(), 1, parser.Errors()+"\n")
+			}
+			if sum.cancelled {
+				writer.InfoMsg(writer.Logout(), msg.MESSAGE, "interrupted, the project is incomplete:\n%s", sum.report(parser))
+			}
+			exitCode = sum.exitCode()
+			summary = "init: " + sum.String()
 		}
-		executeAst(parser)
-		if parser.hasErrors() {
-			writer.LogMsg(writer.Logout(), 1, parser.Errors()+"\n")
+	}
+	pr, prs := cli.Items["presets"].(boa.CmdLineItem[string])
+	if prs {
+		if pr.Value() == "" {
+			fmt.Fprint(os.Stdout, listPresets())
+		} else if src, err := presetSource(pr.Value()); err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitUsage
+		} else {
+			fmt.Fprint(os.Stdout, src)
 		}
 	}
 	_, ren := cli.Items["rename"].(boa.CmdLineItem[string])
@@ -55,14 +163,326 @@ func Run(writer *msg.Messenger) int {
 		// TODO: implement rename later
 		//doRename(cfg)
 		writer.Catch(msg.LOG, errors.New("rename command not implemented"))
-		exitCode = 0
+		exitCode = ExitOK
+	}
+
+	ds, des := cli.Items["designs"].(boa.CmdLineItem[[]string])
+	if des {
+		out, err := doDesigns(ds.Value())
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitUsage
+		}
+		fmt.Fprint(os.Stdout, out)
+	}
+
+	_, val := cli.Items["validate"].(boa.CmdLineItem[bool])
+	if val {
+		label := cfg
+		if pre {
+			label = "preset " + presetName
+		}
+		// a preset is checked as if the project were named after it
+		parser, err := loadDesign(presetName, cfg, presetName, designForm, po)
+		if err != nil {
+			writer.Catch(msg.LOG, fmt.Errorf("unable to read design %s: %v", label, err))
+			exitCode = ExitDesignNotFound
+		} else {
+			v := validateDesign(label, parser)
+			out, err := v.format(form)
+			if err != nil {
+				writer.Catch(msg.LOG, err)
+				exitCode = ExitUsage
+			} else {
+				fmt.Fprint(os.Stdout, out)
+				exitCode = v.exitCode()
+			}
+		}
+	}
+
+	pl, pln := cli.Items["plan"].(boa.CmdLineItem[string])
+	if pln {
+		label := cfg
+		name := pl.Value()
+		if pre {
+			label = "preset " + presetName
+			if name == "" || name == "--" {
+				name = presetName
+			}
+		}
+		parser, err := loadDesign(name, cfg, presetName, designForm, po)
+		if err != nil {
+			writer.Catch(msg.LOG, fmt.Errorf("unable to read design %s: %v", label, err))
+			exitCode = ExitDesignNotFound
+		} else {
+			if parser.hasErrors() {
+				writer.LogMsg(writer.Logout(), 1, parser.Errors()+"\n")
+				exitCode = ExitInvalidDesign
+			}
+			out, err := makePlan(label, parser).format(form)
+			if err != nil {
+				writer.Catch(msg.LOG, err)
+				exitCode = ExitUsage
+			} else {
+				fmt.Fprint(os.Stdout, out)
+			}
+		}
+	}
+
+	nd, nwd := cli.Items["new-design"].(boa.CmdLineItem[string])
+	if nwd {
+		opts := wizardFromCLI(cli, nd.Value())
+		var err error
+		if strings.HasPrefix(opts.file, "-") {
+			// a flag given without a file is taken as the file
+			err = fmt.Errorf("new-design: invalid file name %s, give the file before the flags", opts.file)
+		} else if _, yes := cli.Items["--yes"].(boa.CmdLineItem[bool]); yes {
+			opts, err = checkWizard(opts)
+		} else {
+			opts, err = runWizard(os.Stdin, writer, opts)
+		}
+		if err == nil {
+			err = writeWizardDesign(opts)
+		}
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitUsage
+		} else {
+			writer.InfoMsg(writer.Logout(), msg.MESSAGE, "wrote %s", opts.file)
+		}
+	}
+
+	cp, capt := cli.Items["capture"].(boa.CmdLineItem[string])
+	if capt {
+		opts := captureOptions{inlineSize: DefaultCaptureInlineSize}
+		if n, ok := cli.Items["--name"].(boa.CmdLineItem[string]); ok {
+			opts.name = n.Value()
+		}
+		if s, ok := cli.Items["--source"].(boa.CmdLineItem[string]); ok {
+			opts.source = s.Value()
+		}
+		var err error
+		if ms, ok := cli.Items["--max-size"].(boa.CmdLineItem[string]); ok {
+			opts.maxSize, err = strconv.ParseInt(ms.Value(), 10, 64)
+			if err != nil {
+				err = fmt.Errorf("invalid --max-size %s", ms.Value())
+			}
+		}
+		if is, ok := cli.Items["--inline-size"].(boa.CmdLineItem[string]); ok && err == nil {
+			opts.inlineSize, err = strconv.ParseInt(is.Value(), 10, 64)
+			if err != nil {
+				err = fmt.Errorf("invalid --inline-size %s", is.Value())
+			}
+		}
+		var out string
+		var warn []string
+		if err == nil {
+			out, warn, err = captureDesign(cp.Value(), opts)
+		}
+		for _, w := range warn {
+			writer.InfoMsg(writer.Logout(), msg.MESSAGE, "%s", w)
+		}
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitUsage
+		} else {
+			fmt.Fprint(os.Stdout, out)
+		}
+	}
+
+	df, dif := cli.Items["diff"].(boa.CmdLineItem[string])
+	if dif {
+		label := cfg
+		name := df.Value()
+		if pre {
+			label = "preset " + presetName
+		}
+		if n, ok := cli.Items["--name"].(boa.CmdLineItem[string]); ok {
+			name = n.Value()
+		} else {
+			name = filepath.Base(filepath.Clean(name))
+		}
+		parser, err := loadDesign(name, cfg, presetName, designForm, po)
+		if err != nil {
+			writer.Catch(msg.LOG, fmt.Errorf("unable to read design %s: %v", label, err))
+			exitCode = ExitDesignNotFound
+		} else if parser.hasErrors() {
+			writer.LogMsg(writer.Logout(), 1, parser.Errors()+"\n")
+			exitCode = ExitInvalidDesign
+		} else {
+			var out string
+			d, err := diffDesign(label, df.Value(), parser)
+			if err == nil {
+				out, err = d.format(form)
+			}
+			if err != nil {
+				writer.Catch(msg.LOG, err)
+				exitCode = ExitUsage
+			} else {
+				fmt.Fprint(os.Stdout, out)
+				exitCode = d.exitCode()
+			}
+		}
+	}
+
+	fm, fmd := cli.Items["fmt"].(boa.CmdLineItem[[]string])
+	if fmd {
+		_, write := cli.Items["--write"].(boa.CmdLineItem[bool])
+		_, diff := cli.Items["--diff"].(boa.CmdLineItem[bool])
+		// the file list swallows flags that follow it
+		var files []string
+		for _, f := range fm.Value() {
+			switch f {
+			case "--write", "-w":
+				write = true
+			case "--diff":
+				diff = true
+			default:
+				files = append(files, f)
+			}
+		}
+		if len(files) == 0 {
+			files = []string{cfg}
+		}
+		out, changed, err := doFmt(files, write, diff)
+		fmt.Fprint(os.Stdout, out)
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitInvalidDesign
+		} else if diff && changed {
+			exitCode = ExitDesignDrift
+		}
+	}
+
+	cv, cnv := cli.Items["convert"].(boa.CmdLineItem[string])
+	if cnv {
+		file := cv.Value()
+		if file == "" || file == "--" {
+			file = cfg
+		}
+		var to string
+		if t, ok := cli.Items["--to"].(boa.CmdLineItem[string]); ok {
+			to = t.Value()
+		}
+		out, code, err := doConvert(file, designForm, to)
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = code
+		} else {
+			fmt.Fprint(os.Stdout, out)
+		}
+	}
+
+	_, sch := cli.Items["schema"].(boa.CmdLineItem[bool])
+	if sch {
+		out, err := designSchema()
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitUsage
+		} else {
+			fmt.Fprint(os.Stdout, out)
+		}
+	}
+
+	cm, cmp := cli.Items["completion"].(boa.CmdLineItem[string])
+	if cmp {
+		out, err := completionScript(cm.Value())
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitUsage
+		} else {
+			fmt.Fprint(os.Stdout, out)
+		}
 	}
 
-	if !hlp && !init && !ren { // default command is help
-		ShowHelp(writer)
-		return 0
+	cf, cfs := cli.Items["config"].(boa.CmdLineItem[[]string])
+	if cfs {
+		out, err := doConfig(cf.Value())
+		if err != nil {
+			writer.Catch(msg.LOG, err)
+			exitCode = ExitUsage
+		}
+		fmt.Fprint(os.Stdout, out)
 	}
 
-	writer.InfoMsg(writer.Logout(), msg.MESSAGE, "Exiting with exit code %d", exitCode)
+	if !hlp && !init && !ren && !prs && !des && !val && !pln && !nwd && !capt && !dif && !fmd && !cmp && !cfs && !cnv && !sch { // default command is help
+		ShowHelp(os.Stdout)
+		return ExitOK
+	}
+
+	if summary != "" {
+		summary += ", "
+	}
+	writer.InfoMsg(writer.Logout(), msg.MESSAGE, "%sexit code %d", summary, exitCode)
 	return exitCode
 }
+
+//─────────────┤ loadDesign ├─────────────
+
+// loadDesign parses the built-in preset when one is named, otherwise the
+// design file cfg in format, told by its extension when empty.
+func loadDesign(name, cfg, preset, format string, po parseOptions) (*designParser, error) {
+	if preset == "" {
+		return initProject(name, cfg, format, po)
+	}
+
+	dsn, err := presetDesign(preset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDesignNotFound, err)
+	}
+	return parseDesign(name, dsn, po)
+}
+
+//─────────────┤ cliEvents ├─────────────
+
+// cliEvents reports the progress of init through lg, each step at info and
+// failures at the normal verbosity. The output of commands goes to stdout
+// as it comes.
+func cliEvents(lg *cliLog) func(Event) {
+	steps := 0
+	return func(e Event) {
+		switch e.Kind {
+		case PlanReady:
+			steps = len(e.Nodes)
+			lg.record(VerbosityDebug, &e, "%d steps to run", steps)
+		case NodeStarted:
+			n := e.Node
+			in := ""
+			if n.Command != CmdDir.String() {
+				in = "  [in " + n.Path + "]"
+			}
+			lg.record(VerbosityInfo, &e, "[%d/%d] line %d %s: %s%s", e.Index+1, steps, n.Line, n.Command, paramsText(n.Params), in)
+		case NodeOutput:
+			lg.output(&e)
+		case NodeFinished:
+			if e.Status == NodeDone {
+				lg.record(VerbosityDebug, &e, "[%d/%d] done in %s", e.Index+1, steps, e.Duration.Round(time.Millisecond))
+			} else {
+				lg.record(VerbosityNormal, &e, "[%d/%d] %s after %s: %v", e.Index+1, steps, e.Status, e.Duration.Round(time.Millisecond), e.Err)
+			}
+		case RunFinished:
+			lg.record(VerbosityDebug, &e, "run finished in %s", e.Duration.Round(time.Millisecond))
+		}
+	}
+}
+
+//─────────────┤ splitFlagValues ├─────────────
+
+// splitFlagValues turns --flag=value into --flag value for the flags that
+// take a value, boa only knows the second form
+func splitFlagValues(args []string) []string {
+	valued := map[string]bool{}
+	for _, n := range takesValue() {
+		valued[n] = true
+	}
+
+	var out []string
+	for _, a := range args {
+		if i := strings.Index(a, "="); i > 0 && strings.HasPrefix(a, "-") && valued[a[:i]] {
+			out = append(out, a[:i], a[i+1:])
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
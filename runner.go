@@ -0,0 +1,159 @@
+package goproject
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"bitbucket.org/creachadair/shell"
+)
+
+// Command is an external process a design runs: exec: commands, wget for
+// get:, go for module: and git for git-init:
+type Command struct {
+	Dir  string   `json:"dir"`
+	Args []string `json:"args"`
+}
+
+func (c Command) String() string {
+	return shell.Join(c.Args)
+}
+
+// Runner runs the external processes of a design. Output and errors of the
+// process both go to out.
+type Runner interface {
+	Run(ctx context.Context, cmd Command, out io.Writer) error
+}
+
+//─────────────┤ ExecRunner ├─────────────
+
+// ExecRunner runs commands for real, they are killed when ctx is done
+type ExecRunner struct{}
+
+func (ExecRunner) Run(ctx context.Context, c Command, out io.Writer) error {
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Dir = c.Dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+//─────────────┤ RecordingRunner ├─────────────
+
+// Invocation is a command as a RecordingRunner saw it
+type Invocation struct {
+	Command
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RecordingRunner keeps every command it is given. With a Next runner the
+// commands are passed on and their output and errors recorded too, without
+// one nothing is run and every command succeeds. Directories below Root are
+// recorded relative to it so recordings do not depend on where they were
+// made.
+type RecordingRunner struct {
+	Next  Runner
+	Root  string
+	Calls []Invocation
+}
+
+func (r *RecordingRunner) Run(ctx context.Context, c Command, out io.Writer) error {
+	inv := Invocation{Command: Command{Dir: relDir(r.Root, c.Dir), Args: c.Args}}
+
+	var err error
+	if r.Next != nil {
+		var b strings.Builder
+		err = r.Next.Run(ctx, c, io.MultiWriter(out, &b))
+		inv.Output = b.String()
+		if err != nil {
+			inv.Error = err.Error()
+		}
+	}
+
+	r.Calls = append(r.Calls, inv)
+	return err
+}
+
+// WriteJSON saves the recording in the form LoadReplay reads
+func (r *RecordingRunner) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Calls)
+}
+
+//─────────────┤ ReplayRunner ├─────────────
+
+// ReplayRunner plays back a recording. Each command must be the next one
+// recorded, its output is written and its error returned, nothing is run.
+type ReplayRunner struct {
+	Root  string
+	Calls []Invocation
+	next  int
+}
+
+// LoadReplay reads a recording saved by RecordingRunner.WriteJSON. Root has
+// the meaning it had for the recording.
+func LoadReplay(r io.Reader, root string) (*ReplayRunner, error) {
+	var calls []Invocation
+	if err := json.NewDecoder(r).Decode(&calls); err != nil {
+		return nil, fmt.Errorf("invalid recording: %v", err)
+	}
+	return &ReplayRunner{Root: root, Calls: calls}, nil
+}
+
+func (r *ReplayRunner) Run(ctx context.Context, c Command, out io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	got := Command{Dir: relDir(r.Root, c.Dir), Args: c.Args}
+	if r.next >= len(r.Calls) {
+		return fmt.Errorf("unexpected command %s in %s, the recording has ended", got, got.Dir)
+	}
+	want := r.Calls[r.next]
+	if got.Dir != want.Dir || !reflect.DeepEqual(got.Args, want.Args) {
+		return fmt.Errorf("unexpected command %s in %s, recorded was %s in %s", got, got.Dir, want.Command, want.Dir)
+	}
+	r.next++
+
+	io.WriteString(out, want.Output)
+	if want.Error != "" {
+		return errors.New(want.Error)
+	}
+	return nil
+}
+
+// Done reports the recorded commands that were never run
+func (r *ReplayRunner) Done() error {
+	if r.next == len(r.Calls) {
+		return nil
+	}
+	var left []string
+	for _, c := range r.Calls[r.next:] {
+		left = append(left, c.Command.String())
+	}
+	return fmt.Errorf("%d recorded command(s) not run: %s", len(left), strings.Join(left, "; "))
+}
+
+// relDir is dir relative to root when it lies below it
+func relDir(root, dir string) string {
+	if root == "" {
+		return dir
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return dir
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,133 @@
+package goproject
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// echoRunner writes the arguments of each command, false fails
+type echoRunner struct{}
+
+func (echoRunner) Run(ctx context.Context, c Command, out io.Writer) error {
+	if c.Args[0] == "false" {
+		io.WriteString(out, "failed\n")
+		return errors.New("exit status 1")
+	}
+	io.WriteString(out, strings.Join(c.Args[1:], " ")+"\n")
+	return nil
+}
+
+func TestReplayRunner(t *testing.T) {
+	recording := []Invocation{
+		{Command: Command{Dir: ".", Args: []string{"go", "mod", "init", "app"}}, Output: "created\n"},
+		{Command: Command{Dir: "cmd", Args: []string{"git", "init"}}, Error: "exit status 128"},
+	}
+	tests := []struct {
+		name    string
+		run     []Command
+		wantErr []string // of each command run, "" for none
+		done    string
+	}{
+		{"all run", []Command{
+			{Dir: "/work", Args: []string{"go", "mod", "init", "app"}},
+			{Dir: "/work/cmd", Args: []string{"git", "init"}},
+		}, []string{"", "exit status 128"}, ""},
+		{"some left", []Command{
+			{Dir: "/work", Args: []string{"go", "mod", "init", "app"}},
+		}, []string{""}, "1 recorded command(s) not run: git init"},
+		{"other args", []Command{
+			{Dir: "/work", Args: []string{"go", "mod", "init", "lib"}},
+		}, []string{"unexpected command go mod init lib in ., recorded was go mod init app in ."}, "2 recorded command(s) not run"},
+		{"other dir", []Command{
+			{Dir: "/work/cmd", Args: []string{"go", "mod", "init", "app"}},
+		}, []string{"unexpected command go mod init app in cmd"}, "2 recorded command(s) not run"},
+		{"outside the root", []Command{
+			{Dir: "/elsewhere", Args: []string{"go", "mod", "init", "app"}},
+		}, []string{"in /elsewhere, recorded was"}, "2 recorded command(s) not run"},
+		{"past the end", []Command{
+			{Dir: "/work", Args: []string{"go", "mod", "init", "app"}},
+			{Dir: "/work/cmd", Args: []string{"git", "init"}},
+			{Dir: "/work", Args: []string{"go", "build"}},
+		}, []string{"", "exit status 128", "unexpected command go build in ., the recording has ended"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReplayRunner{Root: "/work", Calls: recording}
+			for i, c := range tt.run {
+				err := r.Run(context.Background(), c, io.Discard)
+				if tt.wantErr[i] == "" && err != nil || tt.wantErr[i] != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr[i])) {
+					t.Errorf("Run(%s) error = %v, want %q", c, err, tt.wantErr[i])
+				}
+			}
+			err := r.Done()
+			if tt.done == "" && err != nil || tt.done != "" && (err == nil || !strings.Contains(err.Error(), tt.done)) {
+				t.Errorf("Done() = %v, want %q", err, tt.done)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &ReplayRunner{Root: "/work", Calls: recording}
+	if err := r.Run(ctx, Command{Dir: "/work", Args: []string{"go", "mod", "init", "app"}}, io.Discard); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run with a cancelled context = %v", err)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	cmds := []Command{
+		{Dir: "/work/app", Args: []string{"echo", "hello", "world"}},
+		{Dir: "/work/app/cmd", Args: []string{"false"}},
+		{Dir: "/tmp", Args: []string{"echo", "outside"}},
+	}
+	run := func(r Runner) (string, []error) {
+		var out bytes.Buffer
+		var errs []error
+		for _, c := range cmds {
+			errs = append(errs, r.Run(context.Background(), c, &out))
+		}
+		return out.String(), errs
+	}
+
+	rec := &RecordingRunner{Next: echoRunner{}, Root: "/work"}
+	wantOut, wantErrs := run(rec)
+	if want := []string{"app", "app/cmd", "/tmp"}; len(rec.Calls) != 3 || rec.Calls[0].Dir != want[0] || rec.Calls[1].Dir != want[1] || rec.Calls[2].Dir != want[2] {
+		t.Fatalf("recorded %+v, want the dirs %q", rec.Calls, want)
+	}
+
+	var b bytes.Buffer
+	if err := rec.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	// the recording replays below another root
+	for i := range cmds {
+		if strings.HasPrefix(cmds[i].Dir, "/work") {
+			cmds[i].Dir = "/other" + strings.TrimPrefix(cmds[i].Dir, "/work")
+		}
+	}
+	rep, err := LoadReplay(&b, "/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotOut, gotErrs := run(rep)
+	if gotOut != wantOut {
+		t.Errorf("replayed output %q, recorded %q", gotOut, wantOut)
+	}
+	for i := range wantErrs {
+		if (gotErrs[i] == nil) != (wantErrs[i] == nil) || gotErrs[i] != nil && gotErrs[i].Error() != wantErrs[i].Error() {
+			t.Errorf("replayed error %d = %v, recorded %v", i, gotErrs[i], wantErrs[i])
+		}
+	}
+	if err := rep.Done(); err != nil {
+		t.Errorf("Done() = %v", err)
+	}
+
+	if _, err := LoadReplay(strings.NewReader("{"), "/work"); err == nil || !strings.Contains(err.Error(), "invalid recording") {
+		t.Errorf("LoadReplay of bad json error = %v", err)
+	}
+}
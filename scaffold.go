@@ -0,0 +1,172 @@
+package goproject
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sourceParams describes a Go source file emitted by main:, package: or
+// test:. name is the base name of a test file and cli selects the flag
+// based command line skeleton for main.go.
+type sourceParams struct {
+	pkg, file, name string
+	cli             bool
+}
+
+var mainTmpl = template.Must(template.New("main").Parse(`package main
+
+import "fmt"
+
+func main() {
+	fmt.Println({{printf "%q" .Project}})
+}
+`))
+
+var cliTmpl = template.Must(template.New("cli").Parse(`package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet({{printf "%q" .Project}}, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flag...] [arg...]\n\nFlags:\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	verbose := fs.Bool("verbose", false, "show more output")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "%s: %d argument(s)\n", fs.Name(), fs.NArg())
+	}
+	return 0
+}
+`))
+
+var docTmpl = template.Must(template.New("doc").Parse(`// Package {{.Package}} ...
+package {{.Package}}
+`))
+
+var testTmpl = template.Must(template.New("test").Parse(`package {{.Package}}
+
+import "testing"
+
+func Test{{.Func}}(t *testing.T) {
+	t.Skip("not implemented")
+}
+`))
+
+//─────────────┤ writeSource ├─────────────
+
+// writeSource renders the file described by sp into dir. Existing files
+// are never overwritten.
+func writeSource(p *designParser, dir string, sp sourceParams) error {
+	file := filepath.Join(dir, sp.file)
+	if _, err := p.fs.Stat(file); err == nil {
+		err = fmt.Errorf("%s already exists", file)
+		p.setError(err)
+		return err
+	}
+
+	src, err := renderSource(dir, sp)
+	if err != nil {
+		p.setError(fmt.Errorf("error generating %s: %v", file, err))
+		return err
+	}
+
+	p.infof("write %s", file)
+	err = p.fs.MkdirAll(dir, 0777)
+	if err == nil {
+		err = p.fs.WriteFile(file, src, 0666)
+	}
+	if err != nil {
+		p.setError(fmt.Errorf("error writing %s", file))
+		return err
+	}
+
+	return nil
+}
+
+//─────────────┤ renderSource ├─────────────
+
+// renderSource returns the formatted content writeSource puts in dir
+func renderSource(dir string, sp sourceParams) ([]byte, error) {
+	tmpl := docTmpl
+	switch {
+	case sp.pkg == "main" && sp.file == "main.go" && sp.cli:
+		tmpl = cliTmpl
+	case sp.pkg == "main" && sp.file == "main.go":
+		tmpl = mainTmpl
+	case strings.HasSuffix(sp.file, "_test.go"):
+		tmpl = testTmpl
+	}
+
+	fn := exportedName(sp.name)
+	if fn == "Main" { // TestMain is reserved by the testing package
+		fn = "MainPackage"
+	}
+
+	var b bytes.Buffer
+	err := tmpl.Execute(&b, map[string]string{
+		"Project": filepath.Base(dir),
+		"Package": sp.pkg,
+		"Func":    fn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(b.Bytes())
+}
+
+//─────────────┤ packageFromDir ├─────────────
+
+// packageFromDir derives a package name from the last element of dir the
+// same way this repository got its name, go-project becomes goproject.
+func packageFromDir(dir string) string {
+	base := strings.ToLower(filepath.Base(dir))
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, base)
+}
+
+//─────────────┤ isPackageName ├─────────────
+
+func isPackageName(name string) bool {
+	return token.IsIdentifier(name) && name != "_"
+}
+
+//─────────────┤ exportedName ├─────────────
+
+// exportedName turns a test name such as parse_args into ParseArgs
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		r, n := utf8.DecodeRuneInString(part)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(part[n:])
+	}
+	return b.String()
+}
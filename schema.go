@@ -0,0 +1,115 @@
+package goproject
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// the JSON Schema dialect of designSchema
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+//─────────────┤ designSchema ├─────────────
+
+// designSchema returns the JSON Schema of the yaml, toml and json designs.
+// It is made from the keywords the parser knows, in the order of their
+// tokens, and the directives registered with Register, described by the
+// directive help.
+func designSchema() (string, error) {
+	kws := []string{"project"}
+	for c := CmdBegin + 1; c < CmdCustom; c++ {
+		kws = append(kws, c.String())
+	}
+	kws = append(kws, "if", "else")
+	kws = append(kws, registeredKeywords()...)
+
+	steps := []any{docMap{{"$ref", "#/$defs/comment"}}}
+	var kwDefs docMap
+	for _, kw := range kws {
+		steps = append(steps, docMap{{"$ref", "#/$defs/" + kw}})
+		kwDefs = append(kwDefs, docField{kw, directiveSchema(kw)})
+	}
+	defs := append(docMap{
+		{"steps", docMap{
+			{"type", "array"},
+			{"items", docMap{{"$ref", "#/$defs/step"}}},
+		}},
+		{"step", docMap{{"oneOf", steps}}},
+		{"lines", docMap{
+			{"description", "The lines of a block, one to an item."},
+			{"type", "array"},
+			{"items", docMap{{"type", "string"}}},
+		}},
+		{"comment", stepSchema("comment", "A comment line.", docMap{{"type", "string"}})},
+	}, kwDefs...)
+
+	schema := docMap{
+		{"$schema", schemaDialect},
+		{"title", AppName + " design"},
+		{"description", "A design in yaml, toml or json, see " + AppName + " help design."},
+		{"type", "object"},
+		{"properties", docMap{
+			{"header", docMap{{"description", "Notes before the design."}, {"type", "string"}}},
+			{"steps", docMap{{"$ref", "#/$defs/steps"}}},
+			{"footer", docMap{{"description", "Notes after the design."}, {"type", "string"}}},
+		}},
+		{"additionalProperties", false},
+		{"$defs", defs},
+	}
+
+	var b bytes.Buffer
+	if err := writeJSON(&b, schema, "  "); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// stepSchema is the schema of a step holding the directive kw
+func stepSchema(kw, desc string, val docMap) docMap {
+	return docMap{
+		{"type", "object"},
+		{"properties", docMap{{kw, append(docMap{{"description", desc}}, val...)}}},
+		{"required", []any{kw}},
+		{"additionalProperties", false},
+	}
+}
+
+// a form of a directive whose text may be left out, eg. git-init: or
+// package: [name]
+var optionalText = regexp.MustCompile(`^[a-z-]+:( \[.*)?$`)
+
+// directiveSchema is the schema of a step of kw. The syntax of its help
+// tells whether the text is optional and whether a block is taken, a
+// registered directive may be given either way.
+func directiveSchema(kw string) docMap {
+	desc := "Added by this build of " + AppName + "."
+	text := docMap{{"type", "string"}}
+	optional, block := false, true
+	for _, d := range directivesHelp {
+		if d.name != kw {
+			continue
+		}
+		desc, block = d.short, false
+		for _, s := range d.syntax {
+			optional = optional || optionalText.MatchString(s)
+			block = block || strings.Contains(s, "(")
+		}
+	}
+	switch {
+	case kw == "else":
+		text = docMap{{"type", []any{"string", "null"}}, {"maxLength", 0}}
+	case optional:
+		text = docMap{{"type", []any{"string", "null"}}}
+	}
+
+	switch {
+	case hasSteps(kw):
+		st := stepSchema(kw, desc, text)
+		props := st[1].val.(docMap)
+		st[1].val = append(props, docField{"steps", docMap{{"$ref", "#/$defs/steps"}}})
+		return st
+	case block:
+		return stepSchema(kw, desc, docMap{{"oneOf", []any{text, docMap{{"$ref", "#/$defs/lines"}}}}})
+	}
+	return stepSchema(kw, desc, text)
+}
@@ -0,0 +1,432 @@
+package goproject
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The toml designs are read and written by hand. Tables, arrays of tables,
+// arrays, inline tables and all four kinds of string are read, other values
+// are kept as the text they are written as. Dotted keys are not read.
+// Comments become comment steps.
+
+//─────────────┤ parseTOML ├─────────────
+
+// parseTOML reads src into maps, lists and strings
+func parseTOML(src string) (any, error) {
+	p := &tomlParser{src: strings.ReplaceAll(src, "\r\n", "\n")}
+	root := map[string]any{}
+	table := root
+
+	for {
+		p.space(true)
+		if p.pos >= len(p.src) {
+			return keepComments(root, p.comments), nil
+		}
+
+		if p.peek("[") {
+			var err error
+			if table, err = p.header(root); err != nil {
+				return nil, err
+			}
+		} else if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.space(false)
+		if p.pos < len(p.src) && p.src[p.pos] != '\n' {
+			return nil, p.errorf("expected the end of the line")
+		}
+	}
+}
+
+type tomlParser struct {
+	src      string
+	pos      int
+	comments []string // read but not yet placed before a step
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+// space passes over blanks and comments, newlines too when lines is set
+func (p *tomlParser) space(lines bool) {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || (lines && c == '\n'):
+			p.pos++
+		case c == '#':
+			start := p.pos + 1
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			p.comments = append(p.comments, strings.TrimSpace(p.src[start:p.pos]))
+		default:
+			return
+		}
+	}
+}
+
+// header reads [table] or [[array.of.tables]] and returns the table that
+// the keys which follow go into
+func (p *tomlParser) header(root map[string]any) (map[string]any, error) {
+	array := p.peek("[[")
+	closing := "]"
+	p.pos++
+	if array {
+		closing = "]]"
+		p.pos++
+	}
+	end := strings.Index(p.src[p.pos:], closing)
+	if end < 0 || strings.Contains(p.src[p.pos:p.pos+end], "\n") {
+		return nil, p.errorf("unterminated table header")
+	}
+	var path []string
+	for _, k := range strings.Split(p.src[p.pos:p.pos+end], ".") {
+		k = strings.TrimSpace(k)
+		if uq, err := strconv.Unquote(k); err == nil {
+			k = uq
+		}
+		if k == "" {
+			return nil, p.errorf("invalid table header")
+		}
+		path = append(path, k)
+	}
+	p.pos += end + len(closing)
+
+	// the tables on the way are the last of an array of tables
+	table := root
+	for _, k := range path[:len(path)-1] {
+		switch v := table[k].(type) {
+		case nil:
+			t := map[string]any{}
+			table[k] = t
+			table = t
+		case map[string]any:
+			table = v
+		case []any:
+			if len(v) == 0 {
+				return nil, p.errorf("%s is not a table", k)
+			}
+			t, ok := v[len(v)-1].(map[string]any)
+			if !ok {
+				return nil, p.errorf("%s is not a table", k)
+			}
+			table = t
+		default:
+			return nil, p.errorf("%s is not a table", k)
+		}
+	}
+
+	// a table of an array is a step, the comments read so far go before it
+	k := path[len(path)-1]
+	t := map[string]any{}
+	switch v := table[k].(type) {
+	case nil:
+		if array {
+			table[k] = append(commentSteps(p.comments), t)
+			p.comments = nil
+		} else {
+			table[k] = t
+		}
+	case []any:
+		if !array {
+			return nil, p.errorf("%s is already an array of tables", k)
+		}
+		table[k] = append(append(v, commentSteps(p.comments)...), t)
+		p.comments = nil
+	default:
+		return nil, p.errorf("%s is already defined", k)
+	}
+	return t, nil
+}
+
+// keyValue reads key = value into table
+func (p *tomlParser) keyValue(table map[string]any) error {
+	k, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.space(false)
+	if !p.peek("=") {
+		return p.errorf("expected = after %s", k)
+	}
+	p.pos++
+	p.space(false)
+
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	if _, ok := table[k]; ok {
+		return p.errorf("duplicate key %s", k)
+	}
+	table[k] = v
+	return nil
+}
+
+func (p *tomlParser) key() (string, error) {
+	if p.peek(`"`) || p.peek("'") {
+		return p.str()
+	}
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !(c == '-' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a key")
+	}
+	if p.peek(".") {
+		return "", p.errorf("dotted keys are not supported, use a [table]")
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *tomlParser) value() (any, error) {
+	switch {
+	case p.peek(`"`) || p.peek("'"):
+		return p.str()
+	case p.peek("["):
+		return p.array()
+	case p.peek("{"):
+		return p.inlineTable()
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t\n#,]}", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("expected a value")
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *tomlParser) array() (any, error) {
+	list := []any{}
+	p.pos++
+	for {
+		p.space(true)
+		if p.peek("]") {
+			p.pos++
+			return list, nil
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated array")
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.space(true)
+		if p.peek(",") {
+			p.pos++
+		} else if !p.peek("]") {
+			return nil, p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (any, error) {
+	t := map[string]any{}
+	p.pos++
+	for {
+		p.space(false)
+		if p.peek("}") {
+			p.pos++
+			return t, nil
+		}
+		if err := p.keyValue(t); err != nil {
+			return nil, err
+		}
+		p.space(false)
+		if p.peek(",") {
+			p.pos++
+		} else if !p.peek("}") {
+			return nil, p.errorf("expected , or } in inline table")
+		}
+	}
+}
+
+// str reads any of the four kinds of string
+func (p *tomlParser) str() (string, error) {
+	switch {
+	case p.peek(`"""`):
+		return p.basic(`"""`)
+	case p.peek("'''"):
+		p.pos += 3
+		end := strings.Index(p.src[p.pos:], "'''")
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		s := strings.TrimPrefix(p.src[p.pos:p.pos+end], "\n")
+		p.pos += end + 3
+		return s, nil
+	case p.peek(`"`):
+		return p.basic(`"`)
+	}
+
+	p.pos++
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// basic reads a basic string closed by delim and resolves its escapes
+func (p *tomlParser) basic(delim string) (string, error) {
+	multi := len(delim) == 3
+	p.pos += len(delim)
+	if multi && p.peek("\n") {
+		p.pos++
+	}
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || (!multi && p.src[p.pos] == '\n') {
+			return "", p.errorf("unterminated string")
+		}
+		if p.peek(delim) {
+			p.pos += len(delim)
+			return b.String(), nil
+		}
+
+		c := p.src[p.pos]
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		p.pos++
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		e := p.src[p.pos]
+		p.pos++
+		switch e {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(e)
+		case 'u', 'U':
+			n := 4
+			if e == 'U' {
+				n = 8
+			}
+			if p.pos+n > len(p.src) {
+				return "", p.errorf("invalid escape")
+			}
+			r, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid escape")
+			}
+			b.WriteRune(rune(r))
+			p.pos += n
+		case ' ', '\t', '\n':
+			// a backslash ending a line of a multi-line string joins it to
+			// the next text
+			if !multi {
+				return "", p.errorf("invalid escape")
+			}
+			p.pos--
+			p.space(true)
+		default:
+			return "", p.errorf("invalid escape \\%c", e)
+		}
+	}
+}
+
+//─────────────┤ encodeTOML ├─────────────
+
+// encodeTOML writes a docMap of lists and strings as toml. Lists of
+// mappings become arrays of tables.
+func encodeTOML(m docMap) string {
+	var b strings.Builder
+	writeTOMLTable(&b, m, "")
+	return strings.TrimPrefix(b.String(), "\n")
+}
+
+func writeTOMLTable(b *strings.Builder, m docMap, path string) {
+	// plain keys come before the tables of the mapping
+	var tables []docField
+	for _, f := range m {
+		if list, ok := f.val.([]any); ok && len(list) > 0 {
+			if _, ok := list[0].(docMap); ok {
+				tables = append(tables, f)
+				continue
+			}
+		}
+		b.WriteString(tomlKey(f.key) + " = " + tomlValue(f.val) + "\n")
+	}
+
+	for _, f := range tables {
+		sub := tomlKey(f.key)
+		if path != "" {
+			sub = path + "." + sub
+		}
+		for _, item := range f.val.([]any) {
+			b.WriteString("\n[[" + sub + "]]\n")
+			writeTOMLTable(b, item.(docMap), sub)
+		}
+	}
+}
+
+func tomlKey(k string) string {
+	for _, c := range k {
+		if !(c == '-' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return quoteString(k)
+		}
+	}
+	return k
+}
+
+func tomlValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		// text over several lines is written as it reads
+		if strings.Contains(v, "\n") && !strings.Contains(v, "'''") && !strings.HasSuffix(v, "'") {
+			return "'''\n" + v + "'''"
+		}
+		return quoteString(v)
+	case []any:
+		var items []string
+		for _, item := range v {
+			items = append(items, tomlValue(item))
+		}
+		if len(items) < 2 {
+			return "[" + strings.Join(items, ", ") + "]"
+		}
+		// an array of several lines is written one to a line
+		return "[\n    " + strings.Join(items, ",\n    ") + ",\n]"
+	case docMap:
+		var fields []string
+		for _, f := range v {
+			fields = append(fields, tomlKey(f.key)+" = "+tomlValue(f.val))
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	}
+	return `""`
+}
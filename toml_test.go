@@ -0,0 +1,129 @@
+package goproject
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want any
+	}{
+		{"empty", "", map[string]any{}},
+		{"keys", "a = \"b\"\nc-d = 'e'\n", map[string]any{"a": "b", "c-d": "e"}},
+		{"quoted key", `"a b" = "c"`, map[string]any{"a b": "c"}},
+		{"bare value", "a = 12\nb = true\n", map[string]any{"a": "12", "b": "true"}},
+		{"escapes", `a = "t\tn\n\"q\" \\ \u00e9"`, map[string]any{"a": "t\tn\n\"q\" \\ é"}},
+		{"literal", `a = 'C:\dir'`, map[string]any{"a": `C:\dir`}},
+		{"multi-line basic", "a = \"\"\"\nx\ny\"\"\"\n", map[string]any{"a": "x\ny"}},
+		{"line ending backslash", "a = \"\"\"\nx \\\n   y\"\"\"\n", map[string]any{"a": "x y"}},
+		{"multi-line literal", "a = '''\nx\n  \\y\n'''\n", map[string]any{"a": "x\n  \\y\n"}},
+		{"array", "a = [\"x\", 'y',]\n", map[string]any{"a": []any{"x", "y"}}},
+		{"array over lines", "a = [\n  \"x\", # one\n  \"y\",\n]\n",
+			map[string]any{"a": []any{"x", "y"}, "steps": []any{map[string]any{"comment": "one"}}}},
+		{"inline table", `a = { b = "c", d = "e" }`, map[string]any{"a": map[string]any{"b": "c", "d": "e"}}},
+		{"table", "[a]\nb = \"c\"\n", map[string]any{"a": map[string]any{"b": "c"}}},
+		{"arrays of tables", "[[a]]\nb = \"c\"\n[[a]]\nb = \"d\"\n[[a.e]]\nf = \"g\"\n",
+			map[string]any{"a": []any{
+				map[string]any{"b": "c"},
+				map[string]any{"b": "d", "e": []any{map[string]any{"f": "g"}}},
+			}}},
+		{"crlf", "a = \"b\"\r\nc = \"d\"\r\n", map[string]any{"a": "b", "c": "d"}},
+		{"comment steps", "# top\n[[steps]]\na = \"b\" # after\n# before\n[[steps]]\nc = \"d\"\n# end\n",
+			map[string]any{"steps": []any{
+				map[string]any{"comment": "top"},
+				map[string]any{"a": "b"},
+				map[string]any{"comment": "after"},
+				map[string]any{"comment": "before"},
+				map[string]any{"c": "d"},
+				map[string]any{"comment": "end"},
+			}}},
+		{"comment nested steps", "[[steps]]\ndir = \"x\"\n# in x\n[[steps.steps]]\na = \"b\"\n",
+			map[string]any{"steps": []any{
+				map[string]any{"dir": "x", "steps": []any{
+					map[string]any{"comment": "in x"},
+					map[string]any{"a": "b"},
+				}},
+			}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(tt.src)
+			if err != nil {
+				t.Fatalf("parseTOML(%q): %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOML(%q) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"missing equals", "a \"b\"\n", "line 1: expected = after a"},
+		{"missing key", "= \"b\"\n", "expected a key"},
+		{"missing value", "a =\n", "expected a value"},
+		{"duplicate key", "a = \"b\"\na = \"c\"\n", "line 2: duplicate key a"},
+		{"dotted key", "a.b = \"c\"\n", "dotted keys are not supported"},
+		{"two values", "a = \"b\" \"c\"\n", "expected the end of the line"},
+		{"unterminated string", "a = \"b\n", "line 1: unterminated string"},
+		{"unterminated literal", "a = 'b\n", "unterminated string"},
+		{"unterminated multi-line", "a = '''b\n", "unterminated string"},
+		{"invalid escape", `a = "\q"`, `invalid escape \q`},
+		{"unterminated array", "a = [\"b\"\n", "expected , or ] in array"},
+		{"unterminated header", "[a\n", "unterminated table header"},
+		{"empty header", "[]\n", "invalid table header"},
+		{"table redefined", "[a]\n[a]\n", "a is already defined"},
+		{"table as array", "[[a]]\n[a]\n", "a is already an array of tables"},
+		{"key not a table", "a = \"b\"\n[a.c]\n", "a is not a table"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTOML(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseTOML(%q) error = %v, want %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   docMap
+		want string
+	}{
+		{"plain", docMap{{"a", "b"}}, "a = \"b\"\n"},
+		{"quoted key", docMap{{"a b", "c"}}, "\"a b\" = \"c\"\n"},
+		{"escaped", docMap{{"a", "say \"hi\"\t"}}, "a = \"say \\\"hi\\\"\\t\"\n"},
+		{"multi-line text", docMap{{"a", "x\ny"}}, "a = '''\nx\ny'''\n"},
+		{"multi-line text ending in a quote", docMap{{"a", "x\n'y'"}}, "a = \"x\\n'y'\"\n"},
+		{"short list", docMap{{"a", []any{"x"}}}, "a = [\"x\"]\n"},
+		{"long list", docMap{{"a", []any{"x", "y"}}}, "a = [\n    \"x\",\n    \"y\",\n]\n"},
+		{"arrays of tables", docMap{{"a", []any{docMap{{"b", "c"}, {"d", []any{docMap{{"e", "f"}}}}}}}},
+			"[[a]]\nb = \"c\"\n\n[[a.d]]\ne = \"f\"\n"},
+		{"inline table", docMap{{"a", docMap{{"b", "c"}}}}, "a = { b = \"c\" }\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := encodeTOML(tt.in)
+			if got != tt.want {
+				t.Errorf("encodeTOML() = %q, want %q", got, tt.want)
+			}
+			if _, err := parseTOML(got); err != nil {
+				t.Errorf("parseTOML(%q): %v", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package goproject
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	path "github.com/rhysd/abspath"
+	"github.com/westarver/helper"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a single finding of validate. Line is 0 when the problem
+// was reported by the parser, whose messages already carry the line.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+type validation struct {
+	Design      string       `json:"design"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func (v *validation) add(sev string, line int, format string, args ...any) {
+	v.Diagnostics = append(v.Diagnostics, Diagnostic{Severity: sev, Line: line, Message: fmt.Sprintf(format, args...)})
+	if sev == SeverityError {
+		v.Errors++
+	} else {
+		v.Warnings++
+	}
+}
+
+//─────────────┤ validateDesign ├─────────────
+
+// validateDesign checks a parsed design without executing any of it.
+func validateDesign(desn string, p *designParser) validation {
+	v := validation{Design: desn, Diagnostics: []Diagnostic{}}
+
+	for _, e := range p.errs {
+		v.add(SeverityError, 0, "%v", e)
+	}
+
+	dirs := map[string]int{}
+	mods := map[string]bool{}
+	for _, n := range p.ast.q {
+		switch n.cmd {
+		case CmdDir:
+			dir := n.cmdParams.(path.AbsPath).String()
+			if first, dup := dirs[dir]; dup {
+				v.add(SeverityWarning, n.line, "directory %s is already created at line %d", dir, first)
+			} else {
+				dirs[dir] = n.line
+			}
+		case CmdModule:
+			mods[n.nest.path.String()] = true
+			validateModulePath(&v, n.line, n.cmdParams.(string))
+		case CmdCustom:
+			cp := n.cmdParams.(customParams)
+			if err := cp.d.Validate(cp.params); err != nil {
+				v.add(SeverityError, n.line, "%s: %v", cp.d.Keyword(), err)
+			}
+		}
+	}
+
+	for _, n := range p.ast.q {
+		switch n.cmd {
+		case CmdWorkspace:
+			dir := n.nest.path.String()
+			for _, m := range n.cmdParams.(workspaceParams).use {
+				rel, err := workspaceRel(dir, m)
+				if err != nil {
+					v.add(SeverityError, n.line, "invalid workspace module %s: %v", m, err)
+					continue
+				}
+				mod := filepath.Join(dir, rel)
+				if mods[mod] {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(mod, "go.mod")); err != nil {
+					v.add(SeverityError, n.line, "workspace module %s is never created by module:", m)
+				}
+			}
+		case CmdCopy:
+			for _, src := range copySources(n.cmdParams.(string)) {
+				if _, err := os.Stat(src); err != nil {
+					v.add(SeverityError, n.line, "copy source %s does not exist", src)
+				}
+			}
+		}
+	}
+
+	return v
+}
+
+//─────────────┤ validateModulePath ├─────────────
+
+func validateModulePath(v *validation, line int, mod string) {
+	if mod == "" {
+		v.add(SeverityError, line, "module: requires a module path")
+		return
+	}
+	if strings.HasPrefix(mod, "/") || strings.HasSuffix(mod, "/") || strings.Contains(mod, "//") {
+		v.add(SeverityError, line, "invalid module path %s", mod)
+		return
+	}
+
+	for _, elem := range strings.Split(mod, "/") {
+		if elem == "." || elem == ".." || strings.HasPrefix(elem, ".") || strings.HasSuffix(elem, ".") {
+			v.add(SeverityError, line, "invalid module path %s: bad element %q", mod, elem)
+			return
+		}
+		for _, r := range elem {
+			if !strings.ContainsRune("-._~+", r) && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9') {
+				v.add(SeverityError, line, "invalid module path %s: invalid character %q", mod, r)
+				return
+			}
+		}
+	}
+
+	first := strings.Split(mod, "/")[0]
+	if !strings.Contains(first, ".") {
+		v.add(SeverityWarning, line, "module path %s has no domain and cannot be fetched with go get", mod)
+	}
+}
+
+//─────────────┤ copySources ├─────────────
+
+// copySources lists the files a copy: directive reads, the directory itself
+// when it names a directory.
+func copySources(src string) []string {
+	if helper.DirExists(src) {
+		return []string{src}
+	}
+
+	var srcs []string
+	for _, s := range splitWithQuotes(src) {
+		s = strings.Trim(s, "\t \"")
+		if len(s) > 0 {
+			srcs = append(srcs, s)
+		}
+	}
+	return srcs
+}
+
+//─────────────┤ format ├─────────────
+
+func (v validation) format(form string) (string, error) {
+	switch form {
+	case "", "text":
+		var b strings.Builder
+		for _, d := range v.Diagnostics {
+			if d.Line > 0 {
+				fmt.Fprintf(&b, "%s:%d: %s: %s\n", v.Design, d.Line, d.Severity, d.Message)
+			} else {
+				fmt.Fprintf(&b, "%s: %s: %s\n", v.Design, d.Severity, d.Message)
+			}
+		}
+		fmt.Fprintf(&b, "%s: %d error(s), %d warning(s)\n", v.Design, v.Errors, v.Warnings)
+		return b.String(), nil
+	case "json":
+		return marshalJSON(v)
+	}
+
+	return "", fmt.Errorf("unknown format %s, use text or json", form)
+}
+
+//─────────────┤ exitCode ├─────────────
+
+func (v validation) exitCode() int {
+	if v.Errors > 0 {
+		return ExitInvalidDesign
+	}
+	if v.Warnings > 0 {
+		return ExitDesignWarnings
+	}
+	return 0
+}
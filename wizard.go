@@ -0,0 +1,270 @@
+package goproject
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	path "github.com/rhysd/abspath"
+	"github.com/westarver/boa"
+)
+
+const NoLicense = "none"
+
+var layoutDirs = []string{"cmd", "internal", "pkg"}
+
+// wizardOptions are the answers new-design needs. Flags fill them in
+// before any question is asked, so they double as the defaults offered.
+type wizardOptions struct {
+	file, project, module string
+	license, holder       string
+	layout                []string
+	git, workspace        bool
+	cfg                   config
+}
+
+//─────────────┤ wizardFromCLI ├─────────────
+
+func wizardFromCLI(cli *boa.CLI, file string) wizardOptions {
+	opts := wizardOptions{file: file, license: NoLicense, layout: []string{"cmd"}}
+	if cfg, err := loadConfig(); err == nil {
+		opts.cfg = cfg
+		opts.holder = cfg.holder()
+		if cfg["license"] != "" {
+			opts.license = cfg["license"]
+		}
+	}
+	if opts.file == "" || opts.file == "--" {
+		opts.file = DefaultCfgFile
+	}
+
+	if wd, err := path.Getwd(); err == nil {
+		opts.project = filepath.Base(wd.String())
+	}
+
+	str := func(flag string, val *string) {
+		if it, ok := cli.Items[flag].(boa.CmdLineItem[string]); ok {
+			*val = it.Value()
+		}
+	}
+	str("--name", &opts.project)
+	str("--module", &opts.module)
+	str("--license", &opts.license)
+	str("--holder", &opts.holder)
+
+	var layout string
+	str("--layout", &layout)
+	if layout != "" {
+		opts.layout = splitList(layout)
+	}
+
+	_, opts.git = cli.Items["--git"].(boa.CmdLineItem[bool])
+	_, opts.workspace = cli.Items["--workspace"].(boa.CmdLineItem[bool])
+
+	return opts
+}
+
+//─────────────┤ runWizard ├─────────────
+
+// runWizard asks for each option in turn, offering the value already in
+// opts as the default. An empty answer or end of input takes the default.
+func runWizard(in io.Reader, out io.Writer, opts wizardOptions) (wizardOptions, error) {
+	r := bufio.NewReader(in)
+	yesno := map[bool]string{true: "y", false: "n"}
+
+	var err error
+	ask := func(q, def string, check func(string) error) string {
+		for err == nil {
+			fmt.Fprintf(out, "%s [%s]: ", q, def)
+			var ans string
+			ans, err = r.ReadString('\n')
+			if errors.Is(err, io.EOF) {
+				err = nil
+				if ans == "" {
+					return def
+				}
+			}
+			ans = strings.Trim(ans, "\t\r\n ")
+			if ans == "" {
+				ans = def
+			}
+			if e := check(ans); e != nil {
+				fmt.Fprintln(out, e)
+				continue
+			}
+			return ans
+		}
+		return def
+	}
+	anything := func(string) error { return nil }
+	isYesNo := func(s string) error {
+		if s != "y" && s != "n" && s != "yes" && s != "no" {
+			return errors.New("answer y or n")
+		}
+		return nil
+	}
+
+	opts.project = ask("Project name", opts.project, checkProject)
+	if opts.module == "" {
+		opts.module = opts.cfg.modulePath(opts.project)
+	}
+	opts.module = ask("Module path", opts.module, checkModule)
+	opts.license = ask("License ("+strings.Join(append(licenseNames(), NoLicense), ", ")+")", opts.license, checkLicense)
+	if opts.license != NoLicense {
+		opts.holder = ask("Copyright holder", opts.holder, anything)
+	}
+	def := strings.Join(opts.layout, ",")
+	if def == "" {
+		def = "none"
+	}
+	opts.layout = splitList(ask("Layout directories ("+strings.Join(layoutDirs, ", ")+" or none)", def, checkLayout))
+	opts.git = strings.HasPrefix(ask("Initialize a git repository (y/n)", yesno[opts.git], isYesNo), "y")
+	opts.workspace = strings.HasPrefix(ask("Create a go.work workspace (y/n)", yesno[opts.workspace], isYesNo), "y")
+
+	return opts, err
+}
+
+//─────────────┤ checkWizard ├─────────────
+
+// checkWizard validates options given entirely by flags, the module path
+// defaults to the module prefix of the config followed by the project name.
+func checkWizard(opts wizardOptions) (wizardOptions, error) {
+	if opts.module == "" {
+		opts.module = opts.cfg.modulePath(opts.project)
+	}
+
+	for _, e := range []error{
+		checkProject(opts.project),
+		checkModule(opts.module),
+		checkLicense(opts.license),
+		checkLayout(strings.Join(opts.layout, ",")),
+	} {
+		if e != nil {
+			return opts, e
+		}
+	}
+	return opts, nil
+}
+
+func checkProject(s string) error {
+	if s == "" || strings.ContainsAny(s, `/\ `) {
+		return fmt.Errorf("invalid project name %q", s)
+	}
+	return nil
+}
+
+func checkModule(s string) error {
+	var v validation
+	validateModulePath(&v, 0, s)
+	if v.Errors > 0 {
+		return errors.New(v.Diagnostics[0].Message)
+	}
+	return nil
+}
+
+func checkLicense(s string) error {
+	if s == NoLicense {
+		return nil
+	}
+	_, err := parseLicenseParams(s)
+	return err
+}
+
+func checkLayout(s string) error {
+	for _, l := range splitList(s) {
+		if !strings.Contains(" "+strings.Join(layoutDirs, " ")+" ", " "+l+" ") {
+			return fmt.Errorf("unknown layout directory %s, use any of %s", l, strings.Join(layoutDirs, ", "))
+		}
+	}
+	return nil
+}
+
+//─────────────┤ wizardDesign ├─────────────
+
+// wizardDesign renders the answers as a design file. Paths use ${project}
+// so the design can be reused by passing another name to init.
+func wizardDesign(opts wizardOptions) string {
+	has := func(dir string) bool {
+		for _, l := range opts.layout {
+			if l == dir {
+				return true
+			}
+		}
+		return false
+	}
+
+	var b strings.Builder
+	b.WriteString("begin-design:\n")
+	b.WriteString("# written by go-project new-design\n")
+	fmt.Fprintf(&b, "project: %s\n\n", opts.project)
+
+	b.WriteString("dir: ${project} (\n")
+	fmt.Fprintf(&b, "    module: %s\n", opts.module)
+	if opts.license != NoLicense {
+		lp, _ := parseLicenseParams(opts.license)
+		fmt.Fprintf(&b, "    license: %s", lp.id)
+		if opts.holder != "" {
+			fmt.Fprintf(&b, " %s", opts.holder)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("    exec: echo \"# ${project}\" > README.md\n")
+	if !has("cmd") {
+		b.WriteString("    package:\n")
+		b.WriteString("    test:\n")
+	}
+	if opts.workspace {
+		b.WriteString("    workspace: .\n")
+	}
+	if opts.git {
+		branch := opts.cfg["git.branch"]
+		if branch == "" {
+			branch = "main"
+		}
+		b.WriteString("    git-init: (\n")
+		fmt.Fprintf(&b, "        branch %s\n", branch)
+		b.WriteString("        attributes\n")
+		b.WriteString("    )\n")
+	}
+	b.WriteString(")\n")
+
+	if has("cmd") {
+		b.WriteString("dir: ${project}/cmd/${project} (\n")
+		b.WriteString("    main: cli\n")
+		b.WriteString(")\n")
+	}
+	for _, l := range []string{"internal", "pkg"} {
+		if has(l) {
+			fmt.Fprintf(&b, "dir: ${project}/%s\n", l)
+		}
+	}
+
+	b.WriteString("end-design:\n")
+	return b.String()
+}
+
+//─────────────┤ writeWizardDesign ├─────────────
+
+func writeWizardDesign(opts wizardOptions) error {
+	if _, err := os.Stat(opts.file); err == nil {
+		return fmt.Errorf("%s already exists", opts.file)
+	}
+	return os.WriteFile(opts.file, []byte(wizardDesign(opts)), 0666)
+}
+
+//─────────────┤ splitList ├─────────────
+
+// splitList splits a comma or space separated list, none is an empty list
+func splitList(s string) []string {
+	var ret []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if f != "none" {
+			ret = append(ret, strings.TrimSuffix(f, "/"))
+		}
+	}
+	return ret
+}
@@ -0,0 +1,210 @@
+package goproject
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// workspaceParams holds the contents of a workspace: directive. The
+// use list is kept exactly as written in the design, paths are resolved
+// against the workspace directory when the go.work file is written.
+type workspaceParams struct {
+	goVersion string
+	use       []string
+	replace   []string
+}
+
+//─────────────┤ parseWorkspaceParams ├─────────────
+
+// parseWorkspaceParams accepts the text following the workspace: keyword
+// with any parentheses already stripped. Each line is either a go version
+// (go 1.18), a replace directive (replace a => b), an optional 'use'
+// keyword followed by module directories, or a list of module directories.
+func parseWorkspaceParams(text string) (workspaceParams, error) {
+	var wp workspaceParams
+
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.Trim(l, "\t ")
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		switch fields[0] {
+		case "go":
+			if len(fields) != 2 {
+				return wp, fmt.Errorf("invalid go version in workspace: %s", l)
+			}
+			wp.goVersion = fields[1]
+		case "replace":
+			rep := strings.Trim(strings.TrimPrefix(l, "replace"), "\t ")
+			if !strings.Contains(rep, "=>") {
+				return wp, fmt.Errorf("invalid replace directive in workspace: %s", l)
+			}
+			wp.replace = append(wp.replace, rep)
+		case "use":
+			wp.use = append(wp.use, fields[1:]...)
+		default:
+			wp.use = append(wp.use, fields...)
+		}
+	}
+
+	return wp, nil
+}
+
+//─────────────┤ writeWorkspace ├─────────────
+
+// writeWorkspace writes go.work into dir. Every module in the use list must
+// already contain a go.mod, if any of them do not nothing is written and
+// all of the offending entries are reported.
+func writeWorkspace(p *designParser, dir string, wp workspaceParams) error {
+	work := filepath.Join(dir, "go.work")
+	if _, err := p.fs.Stat(work); err == nil {
+		err = fmt.Errorf("workspace file %s already exists", work)
+		p.setError(err)
+		return err
+	}
+
+	var failed error
+	var use []string
+	for _, m := range wp.use {
+		rel, err := workspaceRel(dir, m)
+		if err != nil {
+			failed = fmt.Errorf("invalid workspace module %s: %v", m, err)
+			p.setError(failed)
+			continue
+		}
+
+		if _, err := p.fs.Stat(filepath.Join(dir, rel, "go.mod")); err != nil {
+			failed = fmt.Errorf("workspace module %s has no go.mod in %s", m, filepath.Join(dir, rel))
+			p.setError(failed)
+			continue
+		}
+		use = append(use, rel)
+	}
+
+	if failed != nil {
+		return failed
+	}
+
+	// go.work may not name a go version older than one of its modules
+	if wp.goVersion == "" {
+		for _, rel := range use {
+			if v := modGoVersion(p.fs, filepath.Join(dir, rel, "go.mod")); versionLess(wp.goVersion, v) {
+				wp.goVersion = v
+			}
+		}
+	}
+
+	p.infof("write %s", work)
+	err := p.fs.WriteFile(work, []byte(wp.goWork(use)), 0666)
+	if err != nil {
+		p.setError(fmt.Errorf("error writing %s", work))
+		return err
+	}
+
+	return nil
+}
+
+//─────────────┤ goWork ├─────────────
+
+func (wp workspaceParams) goWork(use []string) string {
+	var b strings.Builder
+
+	ver := wp.goVersion
+	if ver == "" {
+		ver = defaultGoVersion()
+	}
+	fmt.Fprintf(&b, "go %s\n", ver)
+
+	if len(use) > 0 {
+		b.WriteString("\nuse (\n")
+		for _, u := range use {
+			fmt.Fprintf(&b, "\t%s\n", u)
+		}
+		b.WriteString(")\n")
+	}
+
+	if len(wp.replace) > 0 {
+		b.WriteString("\n")
+		for _, r := range wp.replace {
+			fmt.Fprintf(&b, "replace %s\n", r)
+		}
+	}
+
+	return b.String()
+}
+
+//─────────────┤ workspaceRel ├─────────────
+
+// workspaceRel returns mod as a slash separated path relative to dir in
+// the form go.work expects, ./name or ../name
+func workspaceRel(dir, mod string) (string, error) {
+	mod = strings.Trim(mod, `"`)
+	if !filepath.IsAbs(mod) {
+		mod = filepath.Join(dir, mod)
+	}
+
+	rel, err := filepath.Rel(dir, mod)
+	if err != nil {
+		return "", err
+	}
+
+	rel = filepath.ToSlash(rel)
+	if rel != "." && !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+	return rel, nil
+}
+
+//─────────────┤ modGoVersion ├─────────────
+
+// modGoVersion is the version of the go directive of the go.mod file, empty
+// when it has none
+func modGoVersion(fsys FS, file string) string {
+	b, err := fsys.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	for _, l := range strings.Split(string(b), "\n") {
+		if f := strings.Fields(l); len(f) == 2 && f[0] == "go" {
+			return f[1]
+		}
+	}
+	return ""
+}
+
+// versionLess reports whether the go version a is older than b, an empty
+// version is older than any other
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return a == "" && b != ""
+}
+
+//─────────────┤ defaultGoVersion ├─────────────
+
+// defaultGoVersion is the language version of the toolchain go-project was
+// built with, used when a design does not give one.
+func defaultGoVersion() string {
+	v := strings.TrimPrefix(runtime.Version(), "go")
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 {
+		return "1.18"
+	}
+	return parts[0] + "." + parts[1]
+}
@@ -0,0 +1,502 @@
+package goproject
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The yaml designs are read and written by hand, they only need mappings,
+// lists and strings. Anchors, tags, flow mappings and documents are not
+// read, every scalar is a string. Comments become comment steps.
+
+//─────────────┤ parseYAML ├─────────────
+
+// parseYAML reads src into maps, lists and strings
+func parseYAML(src string) (any, error) {
+	src = strings.TrimSuffix(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	p := &yamlParser{lines: strings.Split(src, "\n")}
+	p.skip()
+	if p.i >= len(p.lines) {
+		return keepComments(map[string]any{}, p.take(0)), nil
+	}
+	v, err := p.node(p.indent())
+	if err != nil {
+		return nil, err
+	}
+	if p.skip(); p.i < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return keepComments(v, p.take(0)), nil
+}
+
+type yamlParser struct {
+	lines    []string
+	i        int
+	comments []yamlComment // read but not yet placed among the steps
+}
+
+type yamlComment struct {
+	text string
+	ind  int // indentation of the comment, -1 after a value
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.i+1, fmt.Sprintf(format, args...))
+}
+
+// skip passes over blank lines, comments and the document start
+func (p *yamlParser) skip() {
+	for ; p.i < len(p.lines); p.i++ {
+		l := strings.TrimSpace(p.lines[p.i])
+		if strings.HasPrefix(l, "#") {
+			p.comments = append(p.comments, yamlComment{strings.TrimSpace(l[1:]), p.indent()})
+			continue
+		}
+		if l != "" && l != "---" {
+			return
+		}
+	}
+}
+
+// comment keeps the comment c ending a line
+func (p *yamlParser) comment(c string) {
+	p.comments = append(p.comments, yamlComment{strings.TrimSpace(strings.TrimPrefix(c, "#")), -1})
+}
+
+// take returns the comments read so far up to the first on a line of its
+// own indented by less than ind
+func (p *yamlParser) take(ind int) []string {
+	var cs []string
+	for len(p.comments) > 0 {
+		c := p.comments[0]
+		if c.ind >= 0 && c.ind < ind {
+			break
+		}
+		cs = append(cs, c.text)
+		p.comments = p.comments[1:]
+	}
+	return cs
+}
+
+func (p *yamlParser) indent() int {
+	l := p.lines[p.i]
+	return len(l) - len(strings.TrimLeft(l, " "))
+}
+
+func (p *yamlParser) text() string {
+	return strings.TrimSpace(p.lines[p.i])
+}
+
+// more reports whether a line at indentation ind follows
+func (p *yamlParser) more(ind int) bool {
+	p.skip()
+	return p.i < len(p.lines) && p.indent() == ind
+}
+
+// tabbed reports whether the indentation of the line goes on with a tab
+func (p *yamlParser) tabbed() bool {
+	l := p.lines[p.i]
+	return p.indent() < len(l) && l[p.indent()] == '\t'
+}
+
+func isSeqItem(l string) bool {
+	return l == "-" || strings.HasPrefix(l, "- ")
+}
+
+// node reads the list or mapping at indentation ind
+func (p *yamlParser) node(ind int) (any, error) {
+	if isSeqItem(p.text()) {
+		return p.seq(ind)
+	}
+	return p.mapping(ind)
+}
+
+func (p *yamlParser) seq(ind int) (any, error) {
+	list := []any{}
+	steps := false
+	for p.more(ind) && isSeqItem(p.text()) {
+		if p.tabbed() {
+			return nil, p.errorf("tabs are not allowed in indentation")
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(p.text(), "-"))
+		if yamlKey(item) >= 0 {
+			// a mapping is a step, the comments read so far go before it
+			list = append(list, commentSteps(p.take(0))...)
+			steps = true
+		}
+		switch {
+		case item == "":
+			p.i++
+			v, err := p.nested(ind)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		case isSeqItem(item) || yamlKey(item) >= 0:
+			// the item is a node that starts on the line of its dash
+			col := len(strings.TrimRight(p.lines[p.i], " ")) - len(item)
+			p.lines[p.i] = strings.Repeat(" ", col) + item
+			v, err := p.node(col)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		default:
+			v, err := p.scalar(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			p.i++
+		}
+	}
+	if steps {
+		// the comments indented as the steps, or more, end the list
+		list = append(list, commentSteps(p.take(ind))...)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(ind int) (any, error) {
+	m := map[string]any{}
+	for p.more(ind) && !isSeqItem(p.text()) {
+		if p.tabbed() {
+			return nil, p.errorf("tabs are not allowed in indentation")
+		}
+		l := p.text()
+		k := yamlKey(l)
+		if k < 0 {
+			return nil, p.errorf("expected key: value")
+		}
+		kv, err := p.scalar(l[:k])
+		if err != nil {
+			return nil, err
+		}
+		key, ok := kv.(string)
+		if !ok || key == "" {
+			return nil, p.errorf("invalid key %s", l[:k])
+		}
+		if _, ok := m[key]; ok {
+			return nil, p.errorf("duplicate key %s", key)
+		}
+		rest := strings.TrimSpace(l[k+1:])
+
+		var v any
+		switch {
+		case rest == "" || strings.HasPrefix(rest, "#"):
+			if rest != "" {
+				p.comment(rest)
+			}
+			p.i++
+			// a list may sit at the indentation of its key
+			if p.more(ind) && isSeqItem(p.text()) {
+				v, err = p.seq(ind)
+			} else {
+				v, err = p.nested(ind)
+			}
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			v, err = p.block(ind, rest)
+		default:
+			v, err = p.scalar(rest)
+			p.i++
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// nested reads the node indented deeper than ind, nil when there is none
+func (p *yamlParser) nested(ind int) (any, error) {
+	p.skip()
+	if p.i >= len(p.lines) || p.indent() <= ind {
+		return nil, nil
+	}
+	return p.node(p.indent())
+}
+
+// block reads a literal or folded block scalar whose key is at
+// indentation ind, header is what follows the key
+func (p *yamlParser) block(ind int, header string) (string, error) {
+	if i := strings.Index(header, " #"); i >= 0 {
+		p.comment(header[i+1:])
+		header = header[:i]
+	}
+	style, chomp, bi := header[0], byte(0), -1
+	for _, c := range []byte(strings.TrimSpace(header[1:])) {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = c
+		case c >= '1' && c <= '9' && bi < 0:
+			bi = ind + int(c-'0')
+		default:
+			return "", p.errorf("invalid block scalar header %s", header)
+		}
+	}
+
+	p.i++
+	var lines []string
+	for ; p.i < len(p.lines); p.i++ {
+		l := p.lines[p.i]
+		if strings.TrimSpace(l) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		li := len(l) - len(strings.TrimLeft(l, " "))
+		if bi < 0 {
+			bi = li
+		}
+		if li <= ind || li < bi {
+			break
+		}
+		lines = append(lines, l[bi:])
+	}
+
+	// blank lines after the block belong to what follows, a kept end
+	// holds them as newlines
+	blank := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		blank++
+		p.i--
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	var s string
+	if style == '>' {
+		s = foldLines(lines)
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+	switch chomp {
+	case 0:
+		s += "\n"
+	case '+':
+		s += strings.Repeat("\n", blank+1)
+	}
+	return s, nil
+}
+
+// foldLines joins the lines of a folded block scalar, a line break between
+// two lines of text becomes a space and more indented lines are kept as
+// they are
+func foldLines(lines []string) string {
+	text := func(l string) bool { return l != "" && l[0] != ' ' && l[0] != '\t' }
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			switch prev := lines[i-1]; {
+			case text(prev) && text(l):
+				b.WriteByte(' ')
+			case text(prev) && l == "":
+				// the break before empty lines is folded away
+			default:
+				b.WriteByte('\n')
+			}
+		}
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// scalar reads a plain, quoted or flow list value
+func (p *yamlParser) scalar(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := closingQuote(s, '"')
+		if end < 0 {
+			return nil, p.errorf("unterminated string")
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, p.errorf("invalid string %s", s[:end+1])
+		}
+		return v, p.trailing(s[end+1:])
+	case strings.HasPrefix(s, "'"):
+		end := closingQuote(s, '\'')
+		if end < 0 {
+			return nil, p.errorf("unterminated string")
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), p.trailing(s[end+1:])
+	case strings.HasPrefix(s, "["):
+		return p.flow(s)
+	case strings.HasPrefix(s, "|"), strings.HasPrefix(s, ">"):
+		return nil, p.errorf("a block scalar is only read as the value of a key, use a list")
+	case strings.HasPrefix(s, "{"), strings.HasPrefix(s, "&"), strings.HasPrefix(s, "*"), strings.HasPrefix(s, "!"):
+		return nil, p.errorf("%c is not supported in designs", s[0])
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		p.comment(s[i+1:])
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "~" || s == "null" {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// trailing checks that only a comment follows a value
+func (p *yamlParser) trailing(s string) error {
+	s = strings.TrimSpace(s)
+	if s != "" && !strings.HasPrefix(s, "#") {
+		return p.errorf("unexpected %s after value", s)
+	}
+	if s != "" {
+		p.comment(s)
+	}
+	return nil
+}
+
+// flow reads a list of scalars on one line, [a, "b", c]
+func (p *yamlParser) flow(s string) (any, error) {
+	list := []any{}
+	s = strings.TrimSpace(s[1:])
+	for {
+		if strings.HasPrefix(s, "]") {
+			return list, p.trailing(s[1:])
+		}
+		end := flowItemEnd(s)
+		if end < 0 {
+			return nil, p.errorf("unterminated list")
+		}
+		v, err := p.scalar(strings.TrimSpace(s[:end]))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		s = strings.TrimSpace(s[end:])
+		s = strings.TrimSpace(strings.TrimPrefix(s, ","))
+	}
+}
+
+// flowItemEnd is the index of the comma or bracket that ends the first item
+func flowItemEnd(s string) int {
+	start := 0
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		start = closingQuote(s, s[0])
+		if start < 0 {
+			return -1
+		}
+	}
+	i := strings.IndexAny(s[start:], ",]")
+	if i < 0 {
+		return -1
+	}
+	return start + i
+}
+
+// closingQuote is the index of the quote that closes the string s starts
+func closingQuote(s string, q byte) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlKey is the index of the colon ending the key of a mapping line, -1
+// when the line is not one
+func yamlKey(l string) int {
+	start := 0
+	if len(l) > 0 && (l[0] == '"' || l[0] == '\'') {
+		start = closingQuote(l, l[0])
+		if start < 0 {
+			return -1
+		}
+	}
+	for i := start; i < len(l); i++ {
+		if l[i] == ':' && (i+1 == len(l) || l[i+1] == ' ') {
+			return i
+		}
+		if l[i] == '#' && i > 0 && l[i-1] == ' ' {
+			return -1
+		}
+	}
+	return -1
+}
+
+//─────────────┤ encodeYAML ├─────────────
+
+// encodeYAML writes a docMap of lists and strings as yaml
+func encodeYAML(m docMap) string {
+	var b strings.Builder
+	writeYAMLMap(&b, m, "", "")
+	return b.String()
+}
+
+// writeYAMLMap writes the fields of m indented by ind, the first after
+// first instead
+func writeYAMLMap(b *strings.Builder, m docMap, first, ind string) {
+	for i, f := range m {
+		pre := ind
+		if i == 0 {
+			pre = first
+		}
+		switch v := f.val.(type) {
+		case string:
+			if strings.Contains(v, "\n") {
+				b.WriteString(pre + yamlScalar(f.key) + ": |-\n")
+				for _, l := range strings.Split(v, "\n") {
+					b.WriteString(strings.TrimRight(ind+"  "+l, " ") + "\n")
+				}
+				continue
+			}
+			if v == "" {
+				b.WriteString(pre + yamlScalar(f.key) + ":\n")
+				continue
+			}
+			b.WriteString(pre + yamlScalar(f.key) + ": " + yamlScalar(v) + "\n")
+		case []any:
+			if len(v) == 0 {
+				b.WriteString(pre + yamlScalar(f.key) + ": []\n")
+				continue
+			}
+			b.WriteString(pre + yamlScalar(f.key) + ":\n")
+			writeYAMLList(b, v, ind+"  ")
+		case docMap:
+			b.WriteString(pre + yamlScalar(f.key) + ":\n")
+			writeYAMLMap(b, v, ind+"  ", ind+"  ")
+		}
+	}
+}
+
+func writeYAMLList(b *strings.Builder, list []any, ind string) {
+	for _, item := range list {
+		switch v := item.(type) {
+		case string:
+			b.WriteString(ind + "- " + yamlScalar(v) + "\n")
+		case docMap:
+			writeYAMLMap(b, v, ind+"- ", ind+"  ")
+		}
+	}
+}
+
+// yamlScalar is s plain when yaml reads it back as the same string and
+// quoted otherwise
+func yamlScalar(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+		return quoteString(s)
+	}
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off":
+		return quoteString(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return quoteString(s)
+	}
+	return s
+}
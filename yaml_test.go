@@ -0,0 +1,159 @@
+package goproject
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want any
+	}{
+		{"empty", "", map[string]any{}},
+		{"mapping", "a: 1\nb: two words\n", map[string]any{"a": "1", "b": "two words"}},
+		{"nested mapping", "a:\n  b: c\n", map[string]any{"a": map[string]any{"b": "c"}}},
+		{"list", "a:\n  - x\n  - y\n", map[string]any{"a": []any{"x", "y"}}},
+		{"list at key indentation", "a:\n- x\n- y\n", map[string]any{"a": []any{"x", "y"}}},
+		{"list of mappings", "a:\n  - b: c\n    d: e\n  - f: g\n",
+			map[string]any{"a": []any{map[string]any{"b": "c", "d": "e"}, map[string]any{"f": "g"}}}},
+		{"flow list", `a: [x, "y, z", 'w''s']`, map[string]any{"a": []any{"x", "y, z", "w's"}}},
+		{"empty flow list", "a: []\n", map[string]any{"a": []any{}}},
+		{"quoted", `a: "tab\there"` + "\nb: 'it''s'\n", map[string]any{"a": "tab\there", "b": "it's"}},
+		{"null", "a:\nb: ~\nc: null\n", map[string]any{"a": nil, "b": nil, "c": nil}},
+		{"quoted key", `"a b": c`, map[string]any{"a b": "c"}},
+		{"document start", "---\na: b\n", map[string]any{"a": "b"}},
+		{"crlf", "a: b\r\nc: d\r\n", map[string]any{"a": "b", "c": "d"}},
+		{"literal", "a: |\n  x\n    y\nb: c\n", map[string]any{"a": "x\n  y\n", "b": "c"}},
+		{"literal strip", "a: |-\n  x\n  y\n", map[string]any{"a": "x\ny"}},
+		{"literal keep", "a: |+\n  x\n\n", map[string]any{"a": "x\n\n"}},
+		{"literal indentation", "a: |2\n    x\n  y\n", map[string]any{"a": "  x\ny\n"}},
+		{"literal blank line", "a: |\n  x\n\n  y\n", map[string]any{"a": "x\n\ny\n"}},
+		{"folded", "a: >\n  x\n  y\n\n  z\n", map[string]any{"a": "x y\nz\n"}},
+		{"folded strip", "a: >-\n  x\n    y\n  z\n", map[string]any{"a": "x\n  y\nz"}},
+		{"block comment", "a: | # c\n  x\n", map[string]any{"a": "x\n", "steps": []any{map[string]any{"comment": "c"}}}},
+		{"comment steps", "# top\nsteps:\n  - a: b # after\n  # before\n  - c: d\n",
+			map[string]any{"steps": []any{
+				map[string]any{"comment": "top"},
+				map[string]any{"a": "b"},
+				map[string]any{"comment": "after"},
+				map[string]any{"comment": "before"},
+				map[string]any{"c": "d"},
+			}}},
+		{"comment ending nested steps", "steps:\n  - dir: x\n    steps:\n      - a: b\n      # in x\n# at the end\n",
+			map[string]any{"steps": []any{
+				map[string]any{"dir": "x", "steps": []any{
+					map[string]any{"a": "b"},
+					map[string]any{"comment": "in x"},
+				}},
+				map[string]any{"comment": "at the end"},
+			}}},
+		{"only comments", "# a\n# b\n", map[string]any{"steps": []any{map[string]any{"comment": "a"}, map[string]any{"comment": "b"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML(tt.src)
+			if err != nil {
+				t.Fatalf("parseYAML(%q): %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAML(%q) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"tab indentation", "a:\n\t- b\n", "line 2: tabs are not allowed"},
+		{"duplicate key", "a: b\na: c\n", "line 2: duplicate key a"},
+		{"unterminated string", `a: "b`, "line 1: unterminated string"},
+		{"trailing text", `a: "b" c`, "unexpected c after value"},
+		{"unterminated list", "a: [b, c\n", "unterminated list"},
+		{"anchor", "a: &x b\n", "& is not supported"},
+		{"flow mapping", "a: {b: c}\n", "{ is not supported"},
+		{"block scalar item", "a:\n  - |\n    x\n", "a block scalar is only read as the value of a key"},
+		{"block scalar header", "a: |x\n  y\n", "invalid block scalar header"},
+		{"not a mapping", "a: b\nc\n", "line 2: expected key: value"},
+		{"bad indentation", "a:\n    b: c\n  d: e\n", "line 3: unexpected indentation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseYAML(%q) error = %v, want %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   docMap
+		want string
+	}{
+		{"plain", docMap{{"a", "b"}}, "a: b\n"},
+		{"empty text", docMap{{"a", ""}}, "a:\n"},
+		{"multi-line text", docMap{{"a", "x\n  y"}}, "a: |-\n  x\n    y\n"},
+		{"empty list", docMap{{"a", []any{}}}, "a: []\n"},
+		{"list", docMap{{"a", []any{"x", "y"}}}, "a:\n  - x\n  - y\n"},
+		{"list of mappings", docMap{{"a", []any{docMap{{"b", "c"}, {"d", "e"}}}}}, "a:\n  - b: c\n    d: e\n"},
+		{"mapping", docMap{{"a", docMap{{"b", "c"}}}}, "a:\n  b: c\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeYAML(tt.in); got != tt.want {
+				t.Errorf("encodeYAML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLScalar(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"word", "word"},
+		{"two words", "two words"},
+		{"example.com/app", "example.com/app"},
+		{"", `""`},
+		{" lead", `" lead"`},
+		{"- dash", `"- dash"`},
+		{"a: b", `"a: b"`},
+		{"a #b", `"a #b"`},
+		{"end:", `"end:"`},
+		{"true", `"true"`},
+		{"No", `"No"`},
+		{"1.5", `"1.5"`},
+		{"[x]", `"[x]"`},
+		{`say "hi"`, `say "hi"`},
+		{"'q'", `"'q'"`},
+		{"tab\there", `"tab\there"`},
+	}
+
+	for _, tt := range tests {
+		got := yamlScalar(tt.in)
+		if got != tt.want {
+			t.Errorf("yamlScalar(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+		// what is written reads back as the same string
+		v, err := parseYAML("a: " + got)
+		if err != nil {
+			t.Errorf("parseYAML(a: %s): %v", got, err)
+			continue
+		}
+		if back := v.(map[string]any)["a"]; back != tt.in {
+			t.Errorf("yamlScalar(%q) reads back as %#v", tt.in, back)
+		}
+	}
+}